@@ -96,8 +96,12 @@ var paramMatch = regexp.MustCompile(`\$(\~)?(\*|[0-9]+)`)
 // AllNames returns all-alias names for completion
 func AllNames(ctx context.Context) ([]completion.Element, error) {
 	names := make([]completion.Element, 0, len(Table))
-	for name1 := range Table {
-		names = append(names, completion.Element1(name1))
+	for name1, value := range Table {
+		if f, ok := value.(*Func); ok {
+			names = append(names, completion.Element3{name1, name1, f.BaseStr})
+		} else {
+			names = append(names, completion.Element1(name1))
+		}
 	}
 	return names, nil
 }
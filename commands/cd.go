@@ -32,12 +32,29 @@ func pushCdHistory() {
 	}
 }
 
+// OnChangeDir is called with the new and the old directory after
+// cd, pushd, popd and so on change the current directory.
+var OnChangeDir = func(ctx context.Context, newDir, oldDir string) {}
+
+// Chdir changes the current directory and calls OnChangeDir
+// when it is changed.
+func Chdir(ctx context.Context, dir string) error {
+	oldDir, _ := os.Getwd()
+	if err := nodos.Chdir(dir); err != nil {
+		return err
+	}
+	if newDir, err := os.Getwd(); err == nil && newDir != oldDir {
+		OnChangeDir(ctx, newDir, oldDir)
+	}
+	return nil
+}
+
 const (
 	errnoChdirFail = 1
 	errnoNoHistory = 2
 )
 
-func cmdCdSub(dir string) (int, error) {
+func cmdCdSub(ctx context.Context, dir string) (int, error) {
 	const fileHead = "file:///"
 
 	if strings.HasPrefix(dir, fileHead) {
@@ -53,8 +70,9 @@ func cmdCdSub(dir string) (int, error) {
 		// println(dir, "->", dirTmp)
 		dir = dirTmp
 	}
-	err := nodos.Chdir(dir)
+	err := Chdir(ctx, dir)
 	if err == nil {
+		recordDirHistory()
 		return 0, nil
 	}
 	return errnoChdirFail, err
@@ -70,7 +88,7 @@ func cmdCd(ctx context.Context, cmd Param) (int, error) {
 			}
 			directory := cdHistory[len(cdHistory)-1]
 			pushCdHistory()
-			return cmdCdSub(directory)
+			return cmdCdSub(ctx, directory)
 		} else if args[1] == "--history" {
 			dir, err := os.Getwd()
 			if err == nil {
@@ -82,6 +100,12 @@ func cmdCd(ctx context.Context, cmd Param) (int, error) {
 				fmt.Fprintln(cmd.Out(), cdHistory[i])
 			}
 			return 0, nil
+		} else if args[1] == "--jump" {
+			if len(args) < 3 {
+				listRankedDirs(cmd.Out(), nil)
+				return 0, nil
+			}
+			return jump(ctx, args[2:])
 		} else if args[1] == "-h" || args[1] == "?" {
 			i := len(cdHistory) - 10
 			if i < 0 {
@@ -98,19 +122,19 @@ func cmdCd(ctx context.Context, cmd Param) (int, error) {
 			}
 			directory := cdHistory[i]
 			pushCdHistory()
-			return cmdCdSub(directory)
+			return cmdCdSub(ctx, directory)
 		}
 		if strings.EqualFold(args[1], "/D") {
 			// ignore /D
 			args = args[1:]
 		}
 		pushCdHistory()
-		return cmdCdSub(strings.Join(args[1:], " "))
+		return cmdCdSub(ctx, strings.Join(args[1:], " "))
 	}
 	home := nodos.GetHome()
 	if home != "" {
 		pushCdHistory()
-		return cmdCdSub(home)
+		return cmdCdSub(ctx, home)
 	}
 	return cmdPwd(ctx, cmd)
 }
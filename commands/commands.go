@@ -65,6 +65,18 @@ func Exec(ctx context.Context, cmd Param) (int, bool, error) {
 	return next, true, err
 }
 
+// IsBuiltIn returns true when `name` is the name of a built-in command.
+func IsBuiltIn(name string) bool {
+	name = strings.ToLower(name)
+	if m := unscoNamePattern.FindStringSubmatch(name); m != nil {
+		name = m[1]
+	} else if m := backslashPattern.FindStringSubmatch(name); m != nil {
+		name = m[1]
+	}
+	_, ok := buildInCommand[name]
+	return ok
+}
+
 // AllNames returns all command-names for completion package.
 func AllNames(ctx context.Context) ([]completion.Element, error) {
 	names := make([]completion.Element, 0, len(buildInCommand))
@@ -0,0 +1,324 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zetamatta/nyagos/completion"
+	"github.com/zetamatta/nyagos/nodos"
+)
+
+// DirHistoryPath is the file to save the directories visited by `cd`
+// with their frecency. When it is empty, they are not saved.
+var DirHistoryPath = ""
+
+// dirHistoryMaxRank is the sum of ranks to age all entries.
+const dirHistoryMaxRank = 9000
+
+type dirEntry struct {
+	Path string
+	Rank float64
+	Time int64 // the last time visited (UNIX time)
+}
+
+// frecency returns the rank weighted by the time passed since the last visit.
+func (d *dirEntry) frecency(now int64) float64 {
+	dt := now - d.Time
+	switch {
+	case dt < 60*60:
+		return d.Rank * 4
+	case dt < 24*60*60:
+		return d.Rank * 2
+	case dt < 7*24*60*60:
+		return d.Rank / 2
+	default:
+		return d.Rank / 4
+	}
+}
+
+var dirHistoryMutex sync.Mutex
+
+func loadDirHistory() []*dirEntry {
+	result := []*dirEntry{}
+	if DirHistoryPath == "" {
+		return result
+	}
+	fd, err := os.Open(DirHistoryPath)
+	if err != nil {
+		return result
+	}
+	defer fd.Close()
+	return readDirHistory(fd)
+}
+
+func readDirHistory(r io.Reader) []*dirEntry {
+	result := []*dirEntry{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		field := strings.SplitN(sc.Text(), "\t", 3)
+		if len(field) < 3 {
+			continue
+		}
+		rank, err := strconv.ParseFloat(field[0], 64)
+		if err != nil {
+			continue
+		}
+		tm, err := strconv.ParseInt(field[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, &dirEntry{Path: field[2], Rank: rank, Time: tm})
+	}
+	return result
+}
+
+// lockDirHistory opens the file and locks it not to lose the visits of
+// the other instances between reading and saving it. The file may be
+// replaced by the other instances while waiting for the lock.
+func lockDirHistory() (*os.File, error) {
+	for retry := 0; ; retry++ {
+		fd, err := os.OpenFile(DirHistoryPath, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		if err := nodos.LockFile(fd); err != nil {
+			fd.Close()
+			return nil, err
+		}
+		if retry < 3 && isDirHistoryReplaced(fd) {
+			nodos.UnlockFile(fd)
+			fd.Close()
+			continue
+		}
+		return fd, nil
+	}
+}
+
+func isDirHistoryReplaced(fd *os.File) bool {
+	stat1, err := fd.Stat()
+	if err != nil {
+		return false
+	}
+	stat2, err := os.Stat(DirHistoryPath)
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(stat1, stat2)
+}
+
+// saveDirHistory writes entries into the temporary file in the same
+// directory which replaces the file, not to leave the broken file when
+// it fails on the way. unlock is called before renaming again when the
+// file locked can not be replaced.
+func saveDirHistory(entries []*dirEntry, unlock func()) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(DirHistoryPath), filepath.Base(DirHistoryPath)+".")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, d := range entries {
+		fmt.Fprintf(w, "%g\t%d\t%s\n", d.Rank, d.Time, d.Path)
+	}
+	err = w.Flush()
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		if err = os.Rename(tmp.Name(), DirHistoryPath); err != nil {
+			// Windows can not replace the file opened.
+			unlock()
+			err = os.Rename(tmp.Name(), DirHistoryPath)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// addDirHistory increases the rank of the directory. The file is read
+// again on every visit to merge the visits of other instances.
+func addDirHistory(dir string, now int64) error {
+	if DirHistoryPath == "" {
+		return nil
+	}
+	dirHistoryMutex.Lock()
+	defer dirHistoryMutex.Unlock()
+
+	fd, err := lockDirHistory()
+	if err != nil {
+		return err
+	}
+	locked := true
+	unlock := func() {
+		if locked {
+			nodos.UnlockFile(fd)
+			fd.Close()
+			locked = false
+		}
+	}
+	defer unlock()
+
+	entries := readDirHistory(fd)
+	found := false
+	sum := 0.0
+	for _, d := range entries {
+		if strings.EqualFold(d.Path, dir) {
+			d.Path = dir
+			d.Rank++
+			d.Time = now
+			found = true
+		}
+		sum += d.Rank
+	}
+	if !found {
+		entries = append(entries, &dirEntry{Path: dir, Rank: 1, Time: now})
+		sum++
+	}
+	if sum > dirHistoryMaxRank {
+		aged := entries[:0]
+		for _, d := range entries {
+			d.Rank *= 0.99
+			if d.Rank >= 1 {
+				aged = append(aged, d)
+			}
+		}
+		entries = aged
+	}
+	return saveDirHistory(entries, unlock)
+}
+
+func recordDirHistory() {
+	if DirHistoryPath == "" {
+		return
+	}
+	if wd, err := os.Getwd(); err == nil {
+		addDirHistory(wd, time.Now().Unix())
+	}
+}
+
+// matchFragments returns true when all fragments appear in the path in order
+// and the last fragment appears in the last element of the path.
+func matchFragments(path string, fragments []string) bool {
+	pathUpr := strings.ToUpper(path)
+	pos := 0
+	last := -1
+	for _, f := range fragments {
+		i := strings.Index(pathUpr[pos:], strings.ToUpper(f))
+		if i < 0 {
+			return false
+		}
+		last = pos + i
+		pos = last + len(f)
+	}
+	if len(fragments) <= 0 {
+		return true
+	}
+	base := strings.LastIndexAny(strings.TrimRight(pathUpr, `\/`), `\/`)
+	return last > base
+}
+
+// rankedDirs returns the directories existing and matching fragments
+// sorted by frecency.
+func rankedDirs(fragments []string) []*dirEntry {
+	dirHistoryMutex.Lock()
+	entries := loadDirHistory()
+	dirHistoryMutex.Unlock()
+
+	now := time.Now().Unix()
+	result := make([]*dirEntry, 0, len(entries))
+	for _, d := range entries {
+		if !matchFragments(d.Path, fragments) {
+			continue
+		}
+		if stat, err := os.Stat(d.Path); err != nil || !stat.IsDir() {
+			continue
+		}
+		result = append(result, d)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].frecency(now) > result[j].frecency(now)
+	})
+	return result
+}
+
+func listRankedDirs(w io.Writer, fragments []string) {
+	now := time.Now().Unix()
+	for i, d := range rankedDirs(fragments) {
+		fmt.Fprintf(w, "%2d %8.1f  %s\n", i, d.frecency(now), d.Path)
+	}
+}
+
+const errnoNoMatch = 3
+
+// jump moves to the best directory matching fragments.
+func jump(ctx context.Context, fragments []string) (int, error) {
+	// The path given by the completer is used as it is.
+	if last := fragments[len(fragments)-1]; strings.ContainsAny(last, `\/`) {
+		if stat, err := os.Stat(last); err == nil && stat.IsDir() {
+			pushCdHistory()
+			return cmdCdSub(ctx, last)
+		}
+	}
+	dirs := rankedDirs(fragments)
+	if len(dirs) <= 0 {
+		return errnoNoMatch, fmt.Errorf("%s: no directory matches", strings.Join(fragments, " "))
+	}
+	pushCdHistory()
+	return cmdCdSub(ctx, dirs[0].Path)
+}
+
+func cmdZ(ctx context.Context, cmd Param) (int, error) {
+	args := cmd.Args()[1:]
+	if len(args) <= 0 {
+		listRankedDirs(cmd.Out(), nil)
+		return 0, nil
+	}
+	if args[0] == "-l" {
+		listRankedDirs(cmd.Out(), args[1:])
+		return 0, nil
+	}
+	if DirHistoryPath == "" {
+		return errnoNoHistory, errors.New("z: the history of directories is not saved")
+	}
+	return jump(ctx, args)
+}
+
+type zCompleter struct{}
+
+func (zCompleter) String() string {
+	return "Built-in `z` completer"
+}
+
+// Complete lists up the directories matching the fragments typed.
+func (zCompleter) Complete(ctx context.Context, params []string) ([]completion.Element, error) {
+	fragments := []string{}
+	for _, p := range params[1:] {
+		if p != "" && p != "-l" && p != "--jump" {
+			fragments = append(fragments, p)
+		}
+	}
+	if len(fragments) <= 0 {
+		return nil, nil
+	}
+	result := []completion.Element{}
+	for _, d := range rankedDirs(fragments) {
+		result = append(result, completion.Element2{d.Path, filepath.Base(d.Path)})
+	}
+	return result, nil
+}
+
+func init() {
+	completion.CustomCompletion["z"] = zCompleter{}
+}
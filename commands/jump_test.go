@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchFragments(t *testing.T) {
+	tests := []struct {
+		path      string
+		fragments []string
+		expect    bool
+	}{
+		{`/home/user/src/nyagos`, []string{"nya"}, true},
+		{`/home/user/src/nyagos`, []string{"src", "gos"}, true},
+		{`/home/user/src/nyagos`, []string{"gos", "src"}, false},
+		{`/home/user/src/nyagos`, []string{"user"}, false},
+		{`C:\Users\foo\Documents`, []string{"doc"}, true},
+	}
+	for _, test := range tests {
+		if result := matchFragments(test.path, test.fragments); result != test.expect {
+			t.Fatalf("matchFragments(%q,%v)=%v", test.path, test.fragments, result)
+		}
+	}
+}
+
+func TestRankedDirs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "nyagos-jump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	backup := DirHistoryPath
+	DirHistoryPath = filepath.Join(tmp, "nyagos.dirs")
+	defer func() { DirHistoryPath = backup }()
+
+	old := filepath.Join(tmp, "project-old")
+	recent := filepath.Join(tmp, "project-new")
+	os.Mkdir(old, 0777)
+	os.Mkdir(recent, 0777)
+
+	now := time.Now().Unix()
+	for i := 0; i < 5; i++ {
+		addDirHistory(old, now-30*24*60*60)
+	}
+	for i := 0; i < 2; i++ {
+		addDirHistory(recent, now)
+	}
+	addDirHistory(filepath.Join(tmp, "project-removed"), now)
+
+	dirs := rankedDirs([]string{"project"})
+	if len(dirs) != 2 {
+		t.Fatalf("rankedDirs returned %d directories", len(dirs))
+	}
+	if dirs[0].Path != recent {
+		t.Fatalf("the best match is %s, expected %s", dirs[0].Path, recent)
+	}
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"strings"
+
+	"github.com/zetamatta/nyagos/completion"
+	"github.com/zetamatta/nyagos/plugins"
+)
+
+func cmdPlugin(ctx context.Context, cmd Param) (int, error) {
+	return plugins.CmdPlugin(ctx, cmd)
+}
+
+type pluginCompleter struct{}
+
+func (pluginCompleter) String() string {
+	return "Built-in `plugin` completer"
+}
+
+// Complete lists up the sub-commands and the names of the plugins.
+// For `plugin install`, it returns nil to complete the filenames.
+func (pluginCompleter) Complete(ctx context.Context, params []string) ([]completion.Element, error) {
+	var candidates []string
+	if len(params) <= 2 {
+		candidates = plugins.SubCommands
+	} else {
+		switch strings.ToLower(params[1]) {
+		case "info", "enable", "disable", "uninstall":
+			for _, p := range plugins.List() {
+				candidates = append(candidates, p.Name)
+			}
+		default:
+			return nil, nil
+		}
+	}
+	word := strings.ToLower(params[len(params)-1])
+	result := []completion.Element{}
+	for _, name := range candidates {
+		if strings.HasPrefix(strings.ToLower(name), word) {
+			result = append(result, completion.Element1(name))
+		}
+	}
+	return result, nil
+}
+
+func init() {
+	completion.CustomCompletion["plugin"] = pluginCompleter{}
+}
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-
-	"github.com/zetamatta/nyagos/nodos"
 )
 
 var dirstack = make([]string, 0, 20)
@@ -34,7 +32,7 @@ func cmdPopd(ctx context.Context, cmd Param) (int, error) {
 	if len(dirstack) <= 0 {
 		return noDirStack, errors.New("popd: directory stack empty")
 	}
-	err := nodos.Chdir(dirstack[len(dirstack)-1])
+	err := Chdir(ctx, dirstack[len(dirstack)-1])
 	if err != nil {
 		return errnoChdirFail, err
 	}
@@ -49,7 +47,7 @@ func cmdPushd(ctx context.Context, cmd Param) (int, error) {
 	}
 	if len(cmd.Args()) >= 2 {
 		dirstack = append(dirstack, wd)
-		_, err := cmdCdSub(cmd.Arg(1))
+		_, err := cmdCdSub(ctx, cmd.Arg(1))
 		if err != nil {
 			return errnoChdirFail, err
 		}
@@ -57,7 +55,7 @@ func cmdPushd(ctx context.Context, cmd Param) (int, error) {
 		if len(dirstack) <= 0 {
 			return noDirStack, errors.New("pushd: directory stack empty")
 		}
-		err := nodos.Chdir(dirstack[len(dirstack)-1])
+		err := Chdir(ctx, dirstack[len(dirstack)-1])
 		if err != nil {
 			return errnoChdirFail, err
 		}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/zetamatta/nyagos/completion"
+	"github.com/zetamatta/nyagos/history"
 	"github.com/zetamatta/nyagos/readline"
 	"github.com/zetamatta/nyagos/shell"
 	"github.com/zetamatta/nyagos/texts"
@@ -51,16 +52,66 @@ var BoolOptions = map[string]*optionT{
 		Usage:   "Clean up key buffer at prompt",
 		NoUsage: "Do not clean up key buffer at prompt",
 	},
+	"completion_fuzzy": {
+		V:       &completion.UseFuzzy,
+		Usage:   "Match the candidates fuzzily on completion",
+		NoUsage: "Match the prefix of the candidates on completion",
+	},
+	"completion_help": {
+		V:       &completion.UseHelpFlags,
+		Usage:   "Complete flags by parsing `--help` of commands which have no completers",
+		NoUsage: "Do not run `--help` of commands to complete flags",
+	},
 	"completion_hidden": {
 		V:       &completion.IncludeHidden,
 		Usage:   "Include hidden files on completion",
 		NoUsage: "Do not include hidden files on completion",
 	},
+	"completion_menu": {
+		V:       &completion.UseMenu,
+		Usage:   "Select the candidate by cursor keys on completion",
+		NoUsage: "Do not select the candidate by cursor keys on completion",
+	},
 	"completion_slash": {
 		V:       &completion.UseSlash,
 		Usage:   "use forward slash on completion",
 		NoUsage: "Do not use slash on completion",
 	},
+	"highlight": {
+		V:       &readline.EnableHighlight,
+		Usage:   "Colorize the commandline while typing",
+		NoUsage: "Do not colorize the commandline",
+	},
+	"history_erase_dups": {
+		V:       &history.EraseDups,
+		Usage:   "Remove the older history same as the new one",
+		NoUsage: "Keep the older history same as the new one",
+	},
+	"history_ignore_dups": {
+		V:       &history.IgnoreDups,
+		Usage:   "Do not save the history same as the previous one",
+		NoUsage: "Save the history same as the previous one",
+	},
+	"history_ignore_space": {
+		V:       &history.IgnoreSpace,
+		Usage:   "Do not save the history starting with spaces",
+		NoUsage: "Save the history starting with spaces",
+	},
+	"share_history": {
+		V:       &history.ShareHistory,
+		Usage:   "Read the history appended by other sessions before each prompt",
+		NoUsage: "Read the history of other sessions only at startup",
+	},
+	"suggestion": {
+		V:       &readline.EnableSuggestion,
+		Usage:   "Show the history matching the commandline as ghost text",
+		NoUsage: "Do not show the history as ghost text",
+	},
+	"transient_prompt": {
+		V:       &readline.EnableTransientPrompt,
+		Usage:   "Replace the prompt of the line executed with %TRANSIENT_PROMPT%",
+		NoUsage: "Leave the prompt of the line executed as it is",
+	},
 	"glob": {
 		V:       &shell.WildCardExpansionAlways,
 		Usage:   "Enable to expand wildcards",
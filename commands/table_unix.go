@@ -35,6 +35,7 @@ func init() {
 		"mkdir":    cmdMkdir,
 		"more":     cmdMore,
 		"move":     cmdMove,
+		"plugin":   cmdPlugin,
 		"popd":     cmdPopd,
 		"ps":       cmdPs,
 		"pushd":    cmdPushd,
@@ -46,6 +47,7 @@ func init() {
 		"touch":    cmdTouch,
 		"type":     cmdType,
 		"which":    cmdWhich,
+		"z":        cmdZ,
 	}
 }
 
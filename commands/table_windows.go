@@ -45,6 +45,7 @@ func init() {
 		"more":     cmdMore,
 		"move":     cmdMove,
 		"open":     cmdOpen,
+		"plugin":   cmdPlugin,
 		"popd":     cmdPopd,
 		"ps":       cmdPs,
 		"pushd":    cmdPushd,
@@ -58,6 +59,7 @@ func init() {
 		"touch":    cmdTouch,
 		"type":     cmdType,
 		"which":    cmdWhich,
+		"z":        cmdZ,
 	}
 }
 
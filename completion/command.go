@@ -2,40 +2,19 @@ package completion
 
 import (
 	"context"
-	"io/ioutil"
-	"os"
-	"path"
-	"path/filepath"
 	"strings"
+
+	"github.com/zetamatta/nyagos/nodos"
 )
 
 func listUpAllExecutableOnEnv(ctx context.Context, envName string) ([]Element, error) {
-	list := make([]Element, 0, 100)
-	pathEnv := os.Getenv(envName)
-	dirList := filepath.SplitList(pathEnv)
-	for _, dir1 := range dirList {
-		if ctx != nil {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			default:
-			}
-		}
-		files, err := ioutil.ReadDir(dir1)
-		if err != nil {
-			continue
-		}
-		for _, file1 := range files {
-			if file1.IsDir() {
-				continue
-			}
-			name := file1.Name()
-			if isExecutable(name) {
-				name_ := path.Base(name)
-				element := Element1(name_)
-				list = append(list, element)
-			}
-		}
+	names, err := nodos.ListExecutables(ctx, envName)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Element, 0, len(names))
+	for _, name := range names {
+		list = append(list, Element1(name))
 	}
 	return list, nil
 }
@@ -72,18 +51,50 @@ func listUpCommands(ctx context.Context, str string) ([]Element, error) {
 	if listErr != nil {
 		return nil, listErr
 	}
-	strUpr := strings.ToUpper(str)
 	for _, f := range commandListUpper {
 		files, err := f(ctx)
 		if err != nil {
 			return nil, err
 		}
-		for _, element := range files {
-			name1Upr := strings.ToUpper(element.String())
-			if strings.HasPrefix(name1Upr, strUpr) {
-				list = append(list, element)
+		list = appendMatched(list, str, files)
+	}
+	list = removeDup(list)
+	if UseFuzzy {
+		addCommandRecency(list)
+		sortByScore(list)
+	}
+	return list, nil
+}
+
+// appendMatched appends the elements in `source` matching `str` to `list`.
+func appendMatched(list []Element, str string, source []Element) []Element {
+	strUpr := strings.ToUpper(str)
+	for _, element := range source {
+		if UseFuzzy {
+			if score, positions, ok := fuzzyMatch(str, element.String()); ok {
+				if element.Display() != element.String() {
+					positions = nil
+				}
+				list = append(list, newFuzzyElement(element.String(), element.Display(), descriptionOf(element), score, positions))
 			}
+			continue
 		}
+		name1Upr := strings.ToUpper(element.String())
+		if strings.HasPrefix(name1Upr, strUpr) {
+			list = append(list, element)
+		}
+	}
+	return list
+}
+
+func listUpAliases(ctx context.Context, str string) ([]Element, error) {
+	names, err := AliasLister(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := appendMatched(nil, str, names)
+	if UseFuzzy {
+		sortByScore(list)
 	}
-	return removeDup(list), nil
+	return list, nil
 }
@@ -7,8 +7,7 @@ import (
 	"strings"
 	"time"
 	"unicode"
-
-	"github.com/zetamatta/go-box/v2"
+	"unicode/utf8"
 
 	"github.com/zetamatta/nyagos/readline"
 	"github.com/zetamatta/nyagos/texts"
@@ -29,6 +28,34 @@ type Element1 string
 func (s Element1) String() string  { return string(s) }
 func (s Element1) Display() string { return string(s) }
 
+// Describer is the optional interface of Element to show
+// the description with the candidate.
+type Describer interface {
+	Description() string
+}
+
+type Element3 [3]string
+
+func (s Element3) String() string      { return s[0] }
+func (s Element3) Display() string     { return s[1] }
+func (s Element3) Description() string { return s[2] }
+
+func descriptionOf(e Element) string {
+	if d, ok := e.(Describer); ok {
+		return d.Description()
+	}
+	return ""
+}
+
+// replaceText returns the new element whose text to complete is str
+// keeping the display and the description of e.
+func replaceText(e Element, str string) Element {
+	if d := descriptionOf(e); d != "" {
+		return Element3{str, e.Display(), d}
+	}
+	return Element2{str, e.Display()}
+}
+
 type List struct {
 	AllLine string
 	List    []Element
@@ -37,21 +64,12 @@ type List struct {
 	Pos     int
 	Field   []string
 	Left    string
+	Role    Role // syntactic role of the word
+	Quote   rune // quotation mark not closed before the cursor, or 0
 }
 
 var UseSlash = false
 
-func isTop(s string, indexes [][]int) bool {
-	if len(indexes) < 1 {
-		return true
-	}
-	if len(indexes) == 1 {
-		return indexes[0][1] == len(s)
-	}
-	prev := s[indexes[len(indexes)-2][0]:indexes[len(indexes)-2][1]]
-	return prev == ";" || prev == "|" || prev == "&"
-}
-
 type CustomCompleter interface {
 	Complete(context.Context, []string) ([]Element, error)
 	String() string
@@ -92,18 +110,26 @@ func listUpComplete(ctx context.Context, this *readline.Buffer) (*List, rune, er
 		Left:    this.SubString(0, this.Cursor),
 	}
 
-	// environment completion.
-
 	indexes := texts.SplitLikeShell(rv.Left)
 	for _, p := range indexes {
 		rv.Field = append(rv.Field, rv.Left[p[0]:p[1]])
 	}
-	rv.List, rv.Pos, err = listUpEnv(rv.AllLine)
+	wc := lookUpWordContext(rv.Left)
+	rv.Role = wc.Role
+	rv.Quote = wc.Quote
 	default_delimiter := rune(readline.Delimiters[0])
-	if len(rv.List) > 0 && rv.Pos >= 0 && err == nil {
-		rv.RawWord = rv.AllLine[rv.Pos:]
-		rv.Word = rv.RawWord
-		return rv, default_delimiter, nil
+
+	// environment completion.
+	if isVariableWord(wc.Text, wc.Quote) {
+		var pos int
+		rv.List, pos, err = listUpEnv(wc.Text)
+		if len(rv.List) > 0 && pos >= 0 && err == nil {
+			rv.Role = RoleVariable
+			rv.RawWord = rv.Left[wc.Pos+pos:]
+			rv.Pos = utf8.RuneCountInString(rv.Left[:wc.Pos+pos])
+			rv.Word = rv.RawWord
+			return rv, default_delimiter, nil
+		}
 	}
 
 	// filename or commandname completion
@@ -124,9 +150,18 @@ func listUpComplete(ctx context.Context, this *readline.Buffer) (*List, rune, er
 	start := strings.LastIndexAny(rv.Word, ";=") + 1
 
 	replace := false
-	if isTop(rv.Left, indexes) {
+	switch rv.Role {
+	case RoleCommand:
 		rv.List, err = listUpCommands(ctx, rv.Word[start:])
-	} else {
+	case RoleRedirect:
+		// The word may start with the redirect operator as `>foo`
+		if offset := wc.Pos - len(string([]rune(rv.Left)[:rv.Pos])); offset > 0 && offset <= len(rv.Word) {
+			start = offset
+		}
+		rv.List, err = listUpFiles(ctx, rv.Word[start:])
+	case RoleAlias:
+		rv.List, err = listUpAliases(ctx, rv.Word[start:])
+	default:
 		args := make([]string, 0, len(rv.Field))
 		for i, w := range rv.Field {
 			if indexes[i][0] > this.Cursor {
@@ -145,6 +180,8 @@ func listUpComplete(ctx context.Context, this *readline.Buffer) (*List, rune, er
 			} else {
 				rv.List, err = listUpFiles(ctx, rv.Word[start:])
 			}
+		} else if rv.List, err = completeHelpFlags(ctx, args); rv.List != nil && err == nil {
+			replace = true
 		} else {
 			rv.List, err = listUpFiles(ctx, rv.Word[start:])
 		}
@@ -154,10 +191,7 @@ func listUpComplete(ctx context.Context, this *readline.Buffer) (*List, rune, er
 	}
 	if !replace {
 		for i := 0; i < len(rv.List); i++ {
-			rv.List[i] = Element2{
-				rv.Word[:start] + rv.List[i].String(),
-				rv.List[i].Display(),
-			}
+			rv.List[i] = replaceText(rv.List[i], rv.Word[:start]+rv.List[i].String())
 		}
 	}
 	for _, f := range HookToList {
@@ -199,7 +233,7 @@ func KeyFuncCompletionList(ctx context.Context, this *readline.Buffer) readline.
 	if err != nil {
 		fmt.Fprintf(this.Out, "(warning) %s\n", err.Error())
 	}
-	box.Print(ctx, toDisplay(comp.List), this.Out)
+	printCandidates(ctx, comp.List, this.TermWidth, this.Out)
 	this.RepaintAll()
 	return readline.CONTINUE
 }
@@ -244,21 +278,15 @@ func endWithRoot(path string) bool {
 	return len(path) >= 1 && os.IsPathSeparator(path[len(path)-1])
 }
 
-func KeyFuncCompletion(ctx context.Context, this *readline.Buffer) readline.Result {
-	comp, default_delimiter, err := listUpComplete(ctx, this)
-	if err != nil {
-		fmt.Fprintf(this.Out, "\n%s\n", err)
-		this.RepaintAll()
-		return readline.CONTINUE
-	}
-	if comp.List == nil || len(comp.List) <= 0 {
-		return readline.CONTINUE
-	}
-
+// completionText returns the text to replace the current word
+// with quotation marks when it is required.
+func completionText(comp *List, default_delimiter rune) string {
 	complete_list := toComplete(comp.List)
 	commonStr := CommonPrefix(complete_list)
 	quotechar := byte(0)
-	if i := strings.IndexAny(comp.Word, readline.Delimiters); i >= 0 {
+	if comp.Quote != 0 {
+		quotechar = byte(comp.Quote)
+	} else if i := strings.IndexAny(comp.Word, readline.Delimiters); i >= 0 {
 		quotechar = comp.Word[i]
 	} else {
 		for _, node := range complete_list {
@@ -287,12 +315,42 @@ func KeyFuncCompletion(ctx context.Context, this *readline.Buffer) readline.Resu
 	if len(comp.List) == 1 && !endWithRoot(commonStr) && !strings.HasSuffix(commonStr, `%`) {
 		commonStr += " "
 	}
+	return commonStr
+}
+
+func KeyFuncCompletion(ctx context.Context, this *readline.Buffer) readline.Result {
+	comp, default_delimiter, err := listUpComplete(ctx, this)
+	if err != nil {
+		fmt.Fprintf(this.Out, "\n%s\n", err)
+		this.RepaintAll()
+		return readline.CONTINUE
+	}
+	if comp.List == nil || len(comp.List) <= 0 {
+		return readline.CONTINUE
+	}
+
+	commonStr := completionText(comp, default_delimiter)
+	if len(comp.List) == 1 {
+		this.ReplaceAndRepaint(comp.Pos, commonStr)
+		return readline.CONTINUE
+	}
+	if UseFuzzy && !strings.HasPrefix(strings.ToUpper(commonStr), strings.ToUpper(comp.RawWord)) {
+		// The candidates matched fuzzily do not always start with the word.
+		commonStr = comp.RawWord
+	}
 	if comp.RawWord == commonStr {
+		if UseMenu && len(comp.List) >= 2 {
+			if selected := menuSelect(ctx, this, comp.List); selected != nil {
+				comp.List = []Element{selected}
+				this.ReplaceAndRepaint(comp.Pos, completionText(comp, default_delimiter))
+			}
+			return readline.CONTINUE
+		}
 		this.Out.WriteByte('\n')
 		if err != nil {
 			fmt.Fprintf(this.Out, "(warning) %s\n", err.Error())
 		}
-		box.Print(nil, toDisplay(comp.List), this.Out)
+		printCandidates(nil, comp.List, this.TermWidth, this.Out)
 		this.RepaintAll()
 		return readline.CONTINUE
 	}
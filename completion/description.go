@@ -0,0 +1,65 @@
+package completion
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/zetamatta/go-box/v2"
+
+	"github.com/zetamatta/nyagos/readline"
+)
+
+// DescriptionColor is the escape sequence to draw the descriptions
+// of the candidates.
+var DescriptionColor = "\x1B[0;90m"
+
+func hasDescription(list []Element) bool {
+	for _, e := range list {
+		if descriptionOf(e) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// printCandidates prints the candidates. When some of them have
+// the description, they are printed one per line with the descriptions
+// aligned in the second column.
+func printCandidates(ctx context.Context, list []Element, width int, out io.Writer) bool {
+	if !hasDescription(list) {
+		return box.Print(ctx, toDisplay(list), out)
+	}
+	maxLen := 1
+	for _, e := range list {
+		if w := readline.GetStringWidth(box.AnsiCutter.ReplaceAllString(e.Display(), "")); w > maxLen {
+			maxLen = w
+		}
+	}
+	if maxLen > width/2 {
+		maxLen = width / 2
+	}
+	var buffer strings.Builder
+	for _, e := range list {
+		if ctx != nil {
+			select {
+			case <-ctx.Done():
+				return false
+			default:
+			}
+		}
+		buffer.Reset()
+		text, w := truncateWidth(e.Display(), maxLen)
+		buffer.WriteString(text)
+		if d := descriptionOf(e); d != "" {
+			buffer.WriteString(strings.Repeat(" ", maxLen+2-w))
+			d, _ = truncateWidth(strings.Join(strings.Fields(d), " "), width-maxLen-3)
+			buffer.WriteString(DescriptionColor)
+			buffer.WriteString(d)
+			buffer.WriteString(ansiReset)
+		}
+		buffer.WriteByte('\n')
+		io.WriteString(out, buffer.String())
+	}
+	return true
+}
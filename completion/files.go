@@ -35,6 +35,7 @@ func listUpWithFilter(ctx context.Context, str string, filter func(*findfile.Fil
 	}
 	str = strings.Replace(strings.Replace(str, OPT_SLASH, STD_SLASH, -1), `"`, "", -1)
 	directory := DirName(str)
+	pattern := str[len(directory):]
 	wildcard := join(findfile.ExpandEnv(directory), "*")
 
 	// Drive letter
@@ -74,6 +75,16 @@ func listUpWithFilter(ctx context.Context, str string, filter func(*findfile.Fil
 		if cutprefix > 0 {
 			name = name[2:]
 		}
+		if UseFuzzy {
+			if score, positions, ok := fuzzyMatch(pattern, fd.Name()); ok {
+				if orgSlash != STD_SLASH[0] {
+					name = strings.Replace(name, STD_SLASH, OPT_SLASH, -1)
+				}
+				score += recencyScore(fd.ModTime())
+				commons = append(commons, newFuzzyElement(name, listname, "", score, positions))
+			}
+			return true
+		}
 		nameUpr := strings.ToUpper(name)
 		if strings.HasPrefix(nameUpr, STR) {
 			if orgSlash != STD_SLASH[0] {
@@ -84,6 +95,9 @@ func listUpWithFilter(ctx context.Context, str string, filter func(*findfile.Fil
 		}
 		return true
 	})
+	if UseFuzzy {
+		sortByScore(commons)
+	}
 	if canceled != nil {
 		return commons, canceled
 	}
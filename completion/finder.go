@@ -0,0 +1,307 @@
+package completion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zetamatta/nyagos/history"
+	"github.com/zetamatta/nyagos/readline"
+)
+
+// FinderMaxFiles is the max number of files listed by the finder.
+var FinderMaxFiles = 10000
+
+type finderItem struct {
+	Element
+	display string // Display() highlighted with the matched positions
+	score   int
+	order   int
+}
+
+type finderT struct {
+	*readline.Buffer
+	source []Element
+	list   []*finderItem
+	marked map[int]bool
+	filter string
+	cursor int
+	offset int
+}
+
+func (f *finderT) setFilter(filter string) {
+	f.filter = filter
+	f.list = f.list[:0]
+	for i, e := range f.source {
+		score, positions, ok := fuzzyMatch(filter, e.String())
+		if !ok {
+			continue
+		}
+		display := e.Display()
+		if display == e.String() {
+			display = highlightPositions(display, positions)
+		}
+		f.list = append(f.list, &finderItem{Element: e, display: display, score: score, order: i})
+	}
+	if filter != "" {
+		sort.SliceStable(f.list, func(i, j int) bool {
+			return f.list[i].score > f.list[j].score
+		})
+	}
+	f.cursor = 0
+	f.offset = 0
+}
+
+func (f *finderT) draw() {
+	width, height, err := f.TTY.Size()
+	if err != nil {
+		width, height = f.TermWidth, 24
+	}
+	maxRows := height - 2
+	if maxRows <= 0 {
+		maxRows = 1
+	}
+	if f.cursor < f.offset {
+		f.offset = f.cursor
+	} else if f.cursor >= f.offset+maxRows {
+		f.offset = f.cursor - maxRows + 1
+	}
+	rows := 0
+	for i := f.offset; i < len(f.list) && i < f.offset+maxRows; i++ {
+		item := f.list[i]
+		f.Out.WriteString("\n\r")
+		if f.marked[item.order] {
+			f.Out.WriteString("* ")
+		} else {
+			f.Out.WriteString("  ")
+		}
+		display := item.display
+		if i == f.cursor {
+			display = item.Display()
+		}
+		text, w := truncateWidth(display, width-3)
+		if i == f.cursor {
+			f.Out.WriteString(menuSelectedOn)
+			f.Out.WriteString(text)
+			f.Out.WriteString(menuSelectedOff)
+		} else {
+			f.Out.WriteString(text)
+		}
+		if d := descriptionOf(item.Element); d != "" && w+5 < width {
+			d, _ = truncateWidth(d, width-w-5)
+			f.Out.WriteString("  ")
+			f.Out.WriteString(DescriptionColor)
+			f.Out.WriteString(d)
+			f.Out.WriteString(ansiReset)
+		}
+		f.Out.WriteString(menuEraseLine)
+		rows++
+	}
+	f.Out.WriteString("\n\r")
+	status := fmt.Sprintf("(%d/%d", len(f.list), len(f.source))
+	if len(f.marked) > 0 {
+		status += fmt.Sprintf(" +%d", len(f.marked))
+	}
+	status += ") > " + f.filter
+	statusText, _ := truncateWidth(status, width-1)
+	f.Out.WriteString(statusText)
+	f.Out.WriteString(menuEraseLine)
+	f.Out.WriteString(menuEraseBelow)
+	backToLine(f.Buffer, rows+1)
+}
+
+func (f *finderT) erase() {
+	f.Out.WriteString("\n\r")
+	f.Out.WriteString(menuEraseBelow)
+	backToLine(f.Buffer, 1)
+}
+
+func (f *finderT) move(delta int) {
+	if len(f.list) <= 0 {
+		return
+	}
+	f.cursor = (f.cursor + delta + len(f.list)) % len(f.list)
+}
+
+// selected returns the elements marked in the order of the source, or
+// the one under the cursor when nothing is marked.
+func (f *finderT) selected() []Element {
+	if len(f.marked) <= 0 {
+		if len(f.list) <= 0 {
+			return nil
+		}
+		return []Element{f.list[f.cursor].Element}
+	}
+	result := []Element{}
+	for i, e := range f.source {
+		if f.marked[i] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Find shows the elements under the prompt filtered and ranked by
+// the fuzzy matching with the text typed. TAB marks the element to select
+// many. It returns the elements selected, or nil when canceled.
+func Find(ctx context.Context, this *readline.Buffer, source []Element) []Element {
+	if this.TTY == nil || len(source) <= 0 {
+		return nil
+	}
+	f := &finderT{Buffer: this, source: source, marked: map[int]bool{}}
+	f.setFilter("")
+	defer f.erase()
+	for {
+		f.draw()
+		f.Out.Flush()
+		key, err := this.GetKey()
+		if err != nil {
+			return nil
+		}
+		switch key {
+		case "\x1B[B", "\x0E", "\x12":
+			f.move(1)
+		case "\x1B[A", "\x10", "\x13":
+			f.move(-1)
+		case "\t":
+			if len(f.list) > 0 {
+				order := f.list[f.cursor].order
+				if f.marked[order] {
+					delete(f.marked, order)
+				} else {
+					f.marked[order] = true
+				}
+				f.move(1)
+			}
+		case "\r", "\n":
+			return f.selected()
+		case "\x1B", "\x07", "\x03":
+			return nil
+		case "\b", "\x7F":
+			if f.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(f.filter)
+				f.setFilter(f.filter[:len(f.filter)-size])
+			}
+		case "\x15":
+			f.setFilter("")
+		default:
+			if ch, size := utf8.DecodeRuneInString(key); size == len(key) && ch >= ' ' {
+				f.setFilter(f.filter + key)
+			}
+		}
+	}
+}
+
+// historyElements lists the history from the newer one without duplicates
+// with the timestamps and the directories as the descriptions.
+func historyElements(ctx context.Context, this *readline.Buffer) []Element {
+	result := []Element{}
+	found := map[string]bool{}
+	container, ok := ctx.Value(history.PackageId).(*history.Container)
+	for i := this.History.Len() - 1; i >= 0; i-- {
+		text := this.History.At(i)
+		if text == "" || found[text] {
+			continue
+		}
+		found[text] = true
+		if !ok {
+			result = append(result, Element1(text))
+			continue
+		}
+		row := container.Get(i)
+		var description strings.Builder
+		if !row.Stamp.IsZero() {
+			description.WriteString(row.Stamp.Format("Jan _2 15:04"))
+		}
+		if row.Dir != "" {
+			fmt.Fprintf(&description, " (%s)", row.Dir)
+		}
+		result = append(result, Element3{text, text, description.String()})
+	}
+	return result
+}
+
+// errStopWalk stops filepath.Walk of fileElements.
+var errStopWalk = errors.New("stop walking")
+
+// fileElements lists the files under the current directory.
+// When dirOnly is true, only directories are listed.
+func fileElements(ctx context.Context, dirOnly bool) []Element {
+	result := []Element{}
+	filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == "." {
+			return nil
+		}
+		if ctx.Err() != nil || len(result) >= FinderMaxFiles {
+			return errStopWalk
+		}
+		if strings.HasPrefix(info.Name(), ".") && !IncludeHidden {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			path += string(os.PathSeparator)
+		} else if dirOnly {
+			return nil
+		}
+		if UseSlash {
+			path = filepath.ToSlash(path)
+		}
+		result = append(result, Element1(path))
+		return nil
+	})
+	return result
+}
+
+func quoteIfNeeded(s string) string {
+	if strings.ContainsAny(s, " &!") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// KeyFuncFuzzyHistory replaces the commandline with the history selected
+// by Find. The histories selected many are joined with ` ; `
+func KeyFuncFuzzyHistory(ctx context.Context, this *readline.Buffer) readline.Result {
+	selected := Find(ctx, this, historyElements(ctx, this))
+	if len(selected) <= 0 {
+		return readline.CONTINUE
+	}
+	texts := make([]string, 0, len(selected))
+	for _, e := range selected {
+		texts = append(texts, e.String())
+	}
+	this.Cursor = this.Length
+	this.ReplaceAndRepaint(0, strings.Join(texts, " ; "))
+	return readline.CONTINUE
+}
+
+func insertFound(ctx context.Context, this *readline.Buffer, source []Element) readline.Result {
+	selected := Find(ctx, this, source)
+	if len(selected) <= 0 {
+		return readline.CONTINUE
+	}
+	texts := make([]string, 0, len(selected))
+	for _, e := range selected {
+		texts = append(texts, quoteIfNeeded(e.String()))
+	}
+	this.InsertAndRepaint(strings.Join(texts, " "))
+	return readline.CONTINUE
+}
+
+// KeyFuncFuzzyFile inserts the files under the current directory selected by Find.
+func KeyFuncFuzzyFile(ctx context.Context, this *readline.Buffer) readline.Result {
+	return insertFound(ctx, this, fileElements(ctx, false))
+}
+
+// KeyFuncFuzzyDirectory inserts the directories under the current directory selected by Find.
+func KeyFuncFuzzyDirectory(ctx context.Context, this *readline.Buffer) readline.Result {
+	return insertFound(ctx, this, fileElements(ctx, true))
+}
@@ -0,0 +1,43 @@
+package completion
+
+import (
+	"testing"
+)
+
+func TestFinderFilter(t *testing.T) {
+	f := &finderT{
+		source: []Element{
+			Element1("xxreadxmexxxx"),
+			Element1("README.md"),
+			Element1("ls -l"),
+		},
+		marked: map[int]bool{},
+	}
+	f.setFilter("")
+	if len(f.list) != 3 || f.list[0].String() != "xxreadxmexxxx" {
+		t.Fatal("setFilter(``) should keep all elements in the order")
+	}
+	f.setFilter("rdm")
+	if len(f.list) != 2 {
+		t.Fatalf("setFilter(`rdm`) found %d elements", len(f.list))
+	}
+	if f.list[0].String() != "README.md" {
+		t.Fatalf("setFilter(`rdm`): the first is `%s`", f.list[0].String())
+	}
+	f.move(-1)
+	if s := f.selected(); len(s) != 1 || s[0].String() != "xxreadxmexxxx" {
+		t.Fatalf("selected() = %v", s)
+	}
+}
+
+func TestFinderSelectedMarked(t *testing.T) {
+	f := &finderT{
+		source: []Element{Element1("a"), Element1("b"), Element1("c")},
+		marked: map[int]bool{2: true, 0: true},
+	}
+	f.setFilter("")
+	s := f.selected()
+	if len(s) != 2 || s[0].String() != "a" || s[1].String() != "c" {
+		t.Fatalf("selected() = %v", s)
+	}
+}
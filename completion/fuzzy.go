@@ -0,0 +1,187 @@
+package completion
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// UseFuzzy enables the subsequence matching instead of the prefix matching
+// on command-name and filename completion.
+var UseFuzzy = false
+
+// FuzzyMatchColor is the escape sequence to highlight matched characters.
+var FuzzyMatchColor = "\x1B[1;36m"
+
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyScoreConsecutive = 8
+	fuzzyScoreBoundary    = 10
+	fuzzyScoreFirstChar   = 12
+	fuzzyPenaltyGap       = 1
+)
+
+func isWordBoundary(prev, ch rune) bool {
+	switch prev {
+	case '/', '\\', '.', '-', '_', ' ', ':':
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(ch)
+}
+
+// fuzzyMatch tests whether all characters of pattern appear in target
+// in order ignoring case. It returns the score and the positions (index of
+// rune) of the matched characters.
+func fuzzyMatch(pattern, target string) (int, []int, bool) {
+	patternRunes := []rune(strings.ToUpper(pattern))
+	if len(patternRunes) <= 0 {
+		return 0, nil, true
+	}
+	score := 0
+	positions := make([]int, 0, len(patternRunes))
+	prev := rune(0)
+	last := -1
+	p := 0
+	i := 0
+	for _, ch := range target {
+		if p < len(patternRunes) && unicode.ToUpper(ch) == patternRunes[p] {
+			score += fuzzyScoreMatch
+			if i == 0 {
+				score += fuzzyScoreFirstChar
+			} else if isWordBoundary(prev, ch) {
+				score += fuzzyScoreBoundary
+			}
+			if last >= 0 && last == i-1 {
+				score += fuzzyScoreConsecutive
+			} else if last >= 0 {
+				score -= fuzzyPenaltyGap * (i - last - 1)
+			} else {
+				score -= fuzzyPenaltyGap * i
+			}
+			positions = append(positions, i)
+			last = i
+			p++
+		}
+		prev = ch
+		i++
+	}
+	if p < len(patternRunes) {
+		return 0, nil, false
+	}
+	return score, positions, true
+}
+
+// recencyScore gives the bonus to the files modified recently
+// and the commands executed recently.
+func recencyScore(stamp time.Time) int {
+	age := time.Since(stamp)
+	switch {
+	case age < time.Hour:
+		return 8
+	case age < 24*time.Hour:
+		return 4
+	case age < 7*24*time.Hour:
+		return 2
+	}
+	return 0
+}
+
+// commandKey normalizes the command-name to compare the candidate
+// with the one in the history.
+func commandKey(name string) string {
+	name = strings.ToLower(filepath.Base(strings.Trim(name, `"`)))
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// addCommandRecency adds recencyScore of CommandUsage to the scores
+// of the command candidates.
+func addCommandRecency(list []Element) {
+	if CommandUsage == nil {
+		return
+	}
+	used := map[string]time.Time{}
+	for name, stamp := range CommandUsage() {
+		key := commandKey(name)
+		if stamp.After(used[key]) {
+			used[key] = stamp
+		}
+	}
+	if len(used) <= 0 {
+		return
+	}
+	for i, e := range list {
+		if f, ok := e.(fuzzyElement); ok {
+			if stamp, ok := used[commandKey(f.String())]; ok {
+				f.Score += recencyScore(stamp)
+				list[i] = f
+			}
+		}
+	}
+}
+
+// highlightPositions inserts FuzzyMatchColor around the characters
+// at positions.
+func highlightPositions(s string, positions []int) string {
+	if len(positions) <= 0 {
+		return s
+	}
+	var buffer strings.Builder
+	p := 0
+	i := 0
+	on := false
+	for _, ch := range s {
+		match := p < len(positions) && positions[p] == i
+		if match && !on {
+			buffer.WriteString(FuzzyMatchColor)
+			on = true
+		} else if !match && on {
+			buffer.WriteString(ansiReset)
+			on = false
+		}
+		if match {
+			p++
+		}
+		buffer.WriteRune(ch)
+		i++
+	}
+	if on {
+		buffer.WriteString(ansiReset)
+	}
+	return buffer.String()
+}
+
+const ansiReset = "\x1B[0m"
+
+// fuzzyElement is the candidate with the score of fuzzy matching.
+type fuzzyElement struct {
+	Element3
+	Score int
+}
+
+func newFuzzyElement(str, display, description string, score int, positions []int) fuzzyElement {
+	return fuzzyElement{
+		Element3: Element3{str, highlightPositions(display, positions), description},
+		Score:    score,
+	}
+}
+
+// sortByScore sorts the candidates by the score of fuzzy matching.
+// Those which are not matched fuzzily are moved to the tail.
+func sortByScore(list []Element) {
+	score := func(e Element) int {
+		if f, ok := e.(fuzzyElement); ok {
+			return f.Score
+		}
+		return -1 << 30
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		si := score(list[i])
+		sj := score(list[j])
+		if si != sj {
+			return si > sj
+		}
+		return strings.ToUpper(list[i].String()) < strings.ToUpper(list[j].String())
+	})
+}
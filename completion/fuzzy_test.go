@@ -0,0 +1,74 @@
+package completion
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	score, positions, ok := fuzzyMatch("rdm", "README.md")
+	if !ok {
+		t.Fatal("fuzzyMatch(`rdm`,`README.md`) failed")
+	}
+	if len(positions) != 3 || positions[0] != 0 || positions[1] != 3 || positions[2] != 4 {
+		t.Fatalf("fuzzyMatch(`rdm`,`README.md`) positions = %v", positions)
+	}
+	if _, _, ok := fuzzyMatch("rdx", "README.md"); ok {
+		t.Fatal("fuzzyMatch(`rdx`,`README.md`) should fail")
+	}
+	if score1, _, _ := fuzzyMatch("rdm", "xxreadxmexxxx"); score1 >= score {
+		t.Fatalf("the score of the boundary match(%d) should be higher than %d", score, score1)
+	}
+	if score1, _, _ := fuzzyMatch("re", "README.md"); score1 <= 0 {
+		t.Fatalf("fuzzyMatch(`re`,`README.md`) = %d", score1)
+	}
+}
+
+func TestHighlightPositions(t *testing.T) {
+	result := highlightPositions("abcd", []int{1, 2})
+	expect := "a" + FuzzyMatchColor + "bc" + ansiReset + "d"
+	if result != expect {
+		t.Fatalf("highlightPositions(`abcd`,{1,2}) = %q", result)
+	}
+}
+
+func TestSortByScore(t *testing.T) {
+	list := []Element{
+		Element1("zzz"),
+		newFuzzyElement("b", "b", "", 10, nil),
+		newFuzzyElement("a", "a", "", 20, nil),
+		newFuzzyElement("c", "c", "", 10, nil),
+	}
+	sortByScore(list)
+	expect := []string{"a", "b", "c", "zzz"}
+	for i, e := range list {
+		if e.String() != expect[i] {
+			t.Fatalf("sortByScore: %d: %s != %s", i, e.String(), expect[i])
+		}
+	}
+}
+
+func TestAddCommandRecency(t *testing.T) {
+	save := CommandUsage
+	defer func() { CommandUsage = save }()
+	now := time.Now()
+	CommandUsage = func() map[string]time.Time {
+		return map[string]time.Time{
+			"Gitk.exe": now.Add(-time.Minute),
+			"gofmt":    now.Add(-30 * 24 * time.Hour),
+		}
+	}
+	list := []Element{
+		newFuzzyElement("git", "git", "", 20, nil),
+		newFuzzyElement("gitk", "gitk", "", 15, nil),
+		newFuzzyElement("gofmt", "gofmt", "", 15, nil),
+	}
+	addCommandRecency(list)
+	sortByScore(list)
+	expect := []string{"gitk", "git", "gofmt"}
+	for i, e := range list {
+		if e.String() != expect[i] {
+			t.Fatalf("addCommandRecency: %d: %s != %s", i, e.String(), expect[i])
+		}
+	}
+}
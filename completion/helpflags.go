@@ -0,0 +1,206 @@
+package completion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/zetamatta/nyagos/nodos"
+)
+
+// UseHelpFlags enables to complete the flags of commands which have
+// no completers by parsing their `--help` output.
+var UseHelpFlags = false
+
+// HelpCachePath is the file to save the flags parsed from `--help` output.
+// When it is empty, they are not saved.
+var HelpCachePath = ""
+
+var (
+	helpCache       map[string][]*FlagSpec
+	helpCacheMutex  sync.Mutex
+	helpCacheLoaded = false
+	helpRunning     = map[string]bool{}
+)
+
+var helpFlagName = regexp.MustCompile(`^--?[A-Za-z0-9?][-A-Za-z0-9_.]*$`)
+
+// parseHelpFlags reads the flags and their descriptions from the output of
+// `--help` written in the GNU style (`-v, --verbose  description`)
+// or the Go's flag package style (`-name string` + `\tdescription`).
+func parseHelpFlags(text string) []*FlagSpec {
+	result := []*FlagSpec{}
+	found := map[string]bool{}
+	var last *FlagSpec
+	for _, line := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || trimmed == line {
+			last = nil
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "-") {
+			if last != nil && last.Description == "" {
+				last.Description = strings.TrimSpace(trimmed)
+			}
+			last = nil
+			continue
+		}
+		head := trimmed
+		description := ""
+		if i := strings.Index(trimmed, "\t"); i >= 0 {
+			head, description = trimmed[:i], trimmed[i+1:]
+		}
+		if i := strings.Index(head, "  "); i >= 0 {
+			head, description = head[:i], head[i+2:]+description
+		}
+		flag := &FlagSpec{Description: strings.TrimSpace(description)}
+		for _, field := range strings.FieldsFunc(head, func(c rune) bool { return c == ',' || c == ' ' }) {
+			if !strings.HasPrefix(field, "-") {
+				if flag.Short != "" || flag.Long != "" {
+					flag.Arg = argSpecOf(field)
+				}
+				continue
+			}
+			name := field
+			if i := strings.IndexAny(field, "=["); i >= 0 {
+				name = field[:i]
+				flag.Arg = argSpecOf(field[i:])
+			}
+			if !helpFlagName.MatchString(name) || found[name] {
+				continue
+			}
+			found[name] = true
+			if len(name) == 2 && flag.Short == "" {
+				flag.Short = name
+			} else if flag.Long == "" {
+				flag.Long = name
+			}
+		}
+		if flag.Short == "" && flag.Long == "" {
+			last = nil
+			continue
+		}
+		result = append(result, flag)
+		last = flag
+	}
+	return result
+}
+
+func argSpecOf(metavar string) *ArgSpec {
+	if strings.Contains(strings.ToUpper(metavar), "DIR") {
+		return &ArgSpec{Kind: "dir"}
+	}
+	return &ArgSpec{Kind: "file"}
+}
+
+func loadHelpCache() {
+	if helpCacheLoaded {
+		return
+	}
+	helpCacheLoaded = true
+	helpCache = map[string][]*FlagSpec{}
+	if HelpCachePath == "" {
+		return
+	}
+	if data, err := ioutil.ReadFile(HelpCachePath); err == nil {
+		json.Unmarshal(data, &helpCache)
+	}
+}
+
+func saveHelpCache() error {
+	if HelpCachePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(helpCache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(HelpCachePath, data, 0644)
+}
+
+func runHelp(ctx context.Context, path string) []*FlagSpec {
+	for _, opt := range []string{"--help", "-h"} {
+		out, _ := exec.CommandContext(ctx, path, opt).CombinedOutput()
+		if flags := parseHelpFlags(string(out)); len(flags) > 0 {
+			return flags
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	return []*FlagSpec{}
+}
+
+// storeHelpFlags saves the flags of the executable path into the cache.
+// The keys of the same path with the other timestamps are removed.
+func storeHelpFlags(path, key string, flags []*FlagSpec) {
+	helpCacheMutex.Lock()
+	defer helpCacheMutex.Unlock()
+	for key1 := range helpCache {
+		if strings.HasPrefix(key1, path+"\t") && key1 != key {
+			delete(helpCache, key1)
+		}
+	}
+	helpCache[key] = flags
+	if err := saveHelpCache(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+// helpFlags returns the flags of the command name parsed from its help.
+// The result is cached with the path and the timestamp of the executable.
+// When it is not cached yet, the help runs until ctx is done. When it
+// fails or times out, it runs again on the next completion.
+func helpFlags(ctx context.Context, name string) []*FlagSpec {
+	path := name
+	if !strings.ContainsAny(name, `/\`) {
+		path = nodos.FindExecutable(name, "PATH")
+		if path == "" {
+			return nil
+		}
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	key := fmt.Sprintf("%s\t%d", path, stat.ModTime().Unix())
+
+	helpCacheMutex.Lock()
+	loadHelpCache()
+	flags, ok := helpCache[key]
+	running := helpRunning[key]
+	if !ok && !running {
+		helpRunning[key] = true
+	}
+	helpCacheMutex.Unlock()
+	if ok || running {
+		return flags
+	}
+	flags = runHelp(ctx, path)
+	if flags != nil {
+		storeHelpFlags(path, key, flags)
+	}
+	helpCacheMutex.Lock()
+	delete(helpRunning, key)
+	helpCacheMutex.Unlock()
+	return flags
+}
+
+// completeHelpFlags completes the flag with the help of the command args[0].
+func completeHelpFlags(ctx context.Context, args []string) ([]Element, error) {
+	if !UseHelpFlags || len(args) < 2 || !strings.HasPrefix(args[len(args)-1], "-") {
+		return nil, nil
+	}
+	flags := helpFlags(ctx, args[0])
+	if len(flags) <= 0 {
+		return nil, nil
+	}
+	spec := &Spec{Name: args[0], Flags: flags}
+	return spec.Complete(ctx, args)
+}
@@ -0,0 +1,102 @@
+package completion
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseHelpFlagsGNU(t *testing.T) {
+	flags := parseHelpFlags(`Usage: tool [OPTION]... FILE
+Do something.
+
+  -a, --all                  do not ignore entries starting with .
+      --color[=WHEN]         colorize the output
+  -o, --output=FILE          write to FILE
+  -C DIR                     change to DIR
+`)
+	if len(flags) != 4 {
+		t.Fatalf("len(flags) == %d", len(flags))
+	}
+	if f := flags[0]; f.Short != "-a" || f.Long != "--all" || f.Description != "do not ignore entries starting with ." {
+		t.Fatalf("flags[0] == %#v", f)
+	}
+	if f := flags[1]; f.Long != "--color" || f.Arg == nil || f.Description != "colorize the output" {
+		t.Fatalf("flags[1] == %#v", f)
+	}
+	if f := flags[2]; f.Short != "-o" || f.Long != "--output" || f.Arg == nil || f.Arg.Kind != "file" {
+		t.Fatalf("flags[2] == %#v", f)
+	}
+	if f := flags[3]; f.Short != "-C" || f.Arg == nil || f.Arg.Kind != "dir" {
+		t.Fatalf("flags[3] == %#v", f)
+	}
+}
+
+func TestParseHelpFlagsGo(t *testing.T) {
+	flags := parseHelpFlags("Usage of tool:\n  -name string\n    \tthe name\n  -v\tverbose\n")
+	if len(flags) != 2 {
+		t.Fatalf("len(flags) == %d", len(flags))
+	}
+	if f := flags[0]; f.Long != "-name" || f.Arg == nil || f.Description != "the name" {
+		t.Fatalf("flags[0] == %#v", f)
+	}
+	if f := flags[1]; f.Short != "-v" || f.Description != "verbose" {
+		t.Fatalf("flags[1] == %#v", f)
+	}
+}
+
+func TestStoreHelpFlags(t *testing.T) {
+	saveCache, saveLoaded, savePath := helpCache, helpCacheLoaded, HelpCachePath
+	defer func() { helpCache, helpCacheLoaded, HelpCachePath = saveCache, saveLoaded, savePath }()
+	HelpCachePath = ""
+	helpCache = map[string][]*FlagSpec{
+		"/bin/tool\t100":  {},
+		"/bin/tool2\t100": {},
+	}
+	helpCacheLoaded = true
+	storeHelpFlags("/bin/tool", "/bin/tool\t200", []*FlagSpec{{Short: "-a"}})
+	if _, ok := helpCache["/bin/tool\t100"]; ok {
+		t.Fatal("the stale key is not removed")
+	}
+	if _, ok := helpCache["/bin/tool2\t100"]; !ok {
+		t.Fatal("the key of the other path is removed")
+	}
+	if flags := helpCache["/bin/tool\t200"]; len(flags) != 1 {
+		t.Fatalf("helpCache[/bin/tool\\t200] == %v", flags)
+	}
+}
+
+func TestHelpFlagsTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command is a shell script")
+	}
+	saveCache, saveLoaded, savePath := helpCache, helpCacheLoaded, HelpCachePath
+	defer func() { helpCache, helpCacheLoaded, HelpCachePath = saveCache, saveLoaded, savePath }()
+	HelpCachePath = ""
+	helpCache = map[string][]*FlagSpec{}
+	helpCacheLoaded = true
+
+	dir, err := ioutil.TempDir("", "nyagos-helpflags")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	tool := filepath.Join(dir, "tool")
+	script := "#!/bin/sh\necho '  -a, --all  all'\n"
+	if err := ioutil.WriteFile(tool, []byte(script), 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if flags := helpFlags(ctx, tool); flags != nil {
+		t.Fatalf("helpFlags with the canceled context = %v", flags)
+	}
+	// it runs again after it failed.
+	if flags := helpFlags(context.Background(), tool); len(flags) != 1 || flags[0].Long != "--all" {
+		t.Fatalf("helpFlags = %v", flags)
+	}
+}
@@ -2,6 +2,8 @@ package completion
 
 import (
 	"context"
+	"time"
+
 	"github.com/zetamatta/nyagos/readline"
 )
 
@@ -19,6 +21,14 @@ func AppendCommandLister(f func(context.Context) ([]Element, error)) {
 	commandListUpper = append(commandListUpper, f)
 }
 
+// AliasLister lists up the names of aliases to complete the argument of `alias`.
+var AliasLister func(context.Context) ([]Element, error)
+
+// CommandUsage returns when the commands were executed last.
+// Its keys are the command-names as typed. It is used to rank the
+// commands by recency on the fuzzy completion.
+var CommandUsage func() map[string]time.Time
+
 // HookToList is the slice for Completion-Hook functions for users.
 var HookToList = []func(context.Context, *readline.Buffer, *List) (*List, error){}
 
@@ -28,4 +38,12 @@ func init() {
 	if err != nil {
 		panic(err.Error())
 	}
+
+	readline.NAME2FUNC[readline.F_FUZZY_HISTORY] = KeyFuncFuzzyHistory
+	readline.NAME2FUNC[readline.F_FUZZY_FILE] = KeyFuncFuzzyFile
+	readline.NAME2FUNC[readline.F_FUZZY_DIRECTORY] = KeyFuncFuzzyDirectory
+	err = readline.BindKeySymbol(readline.K_CTRL_R, readline.F_FUZZY_HISTORY)
+	if err != nil {
+		panic(err.Error())
+	}
 }
@@ -0,0 +1,232 @@
+package completion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zetamatta/go-box/v2"
+
+	"github.com/zetamatta/nyagos/readline"
+)
+
+// UseMenu enables to select the candidate on the grid by cursor keys
+// instead of listing them when TAB is typed again.
+var UseMenu = false
+
+const (
+	menuSelectedOn  = "\x1B[7m"
+	menuSelectedOff = "\x1B[0m"
+	menuEraseLine   = "\x1B[0K"
+	menuEraseBelow  = "\x1B[0J"
+)
+
+// truncateWidth cuts s into w-cells ignoring escape sequences.
+func truncateWidth(s string, w int) (string, int) {
+	var buffer strings.Builder
+	width := 0
+	escape := false
+	for _, ch := range s {
+		if ch == '\x1B' || escape {
+			buffer.WriteRune(ch)
+			escape = !unicode.IsLetter(ch)
+			continue
+		}
+		w1 := readline.GetCharWidth(ch)
+		if width+w1 > w {
+			break
+		}
+		buffer.WriteRune(ch)
+		width += w1
+	}
+	if escape || strings.ContainsRune(s, '\x1B') {
+		buffer.WriteString(ansiReset)
+	}
+	return buffer.String(), width
+}
+
+func filterElements(source []Element, filter string) []Element {
+	if filter == "" {
+		return source
+	}
+	filter = strings.ToUpper(filter)
+	result := make([]Element, 0, len(source))
+	for _, e := range source {
+		if strings.Contains(strings.ToUpper(box.AnsiCutter.ReplaceAllString(e.Display(), "")), filter) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+type menuT struct {
+	*readline.Buffer
+	source []Element
+	list   []Element
+	filter string
+	cursor int
+	offset int
+	nlines int
+}
+
+// backToLine moves the cursor from the n-th line after the readline-buffer
+// to the position of the cursor on it.
+func backToLine(b *readline.Buffer, n int) {
+	if n > 0 {
+		fmt.Fprintf(b.Out, "\x1B[%dA", n)
+	}
+	b.Out.WriteByte('\r')
+	if col := b.TopColumn + b.GetWidthBetween(b.ViewStart, b.Cursor); col > 0 {
+		fmt.Fprintf(b.Out, "\x1B[%dC", col)
+	}
+}
+
+func (m *menuT) draw() {
+	width, height, err := m.TTY.Size()
+	if err != nil {
+		width, height = m.TermWidth, 24
+	}
+	maxLen := 1
+	for _, e := range m.list {
+		if w := readline.GetStringWidth(box.AnsiCutter.ReplaceAllString(e.Display(), "")); w > maxLen {
+			maxLen = w
+		}
+	}
+	if maxLen > width-2 {
+		maxLen = width - 2
+	}
+	perLine := (width - 1) / (maxLen + 1)
+	if perLine <= 0 {
+		perLine = 1
+	}
+	m.nlines = (len(m.list) + perLine - 1) / perLine
+	maxRows := height - 2
+	if maxRows <= 0 {
+		maxRows = 1
+	}
+	if m.nlines > 0 {
+		if y := m.cursor % m.nlines; y < m.offset {
+			m.offset = y
+		} else if y >= m.offset+maxRows {
+			m.offset = y - maxRows + 1
+		}
+	}
+	rows := 0
+	for y := m.offset; y < m.nlines && y < m.offset+maxRows; y++ {
+		m.Out.WriteString("\n\r")
+		for x := 0; x < perLine; x++ {
+			i := x*m.nlines + y
+			if i >= len(m.list) {
+				break
+			}
+			display := m.list[i].Display()
+			if i == m.cursor {
+				display = box.AnsiCutter.ReplaceAllString(display, "")
+			}
+			text, w := truncateWidth(display, maxLen)
+			if i == m.cursor {
+				m.Out.WriteString(menuSelectedOn)
+				m.Out.WriteString(text)
+				m.Out.WriteString(menuSelectedOff)
+			} else {
+				m.Out.WriteString(text)
+			}
+			m.Out.WriteString(strings.Repeat(" ", maxLen+1-w))
+		}
+		m.Out.WriteString(menuEraseLine)
+		rows++
+	}
+	m.Out.WriteString("\n\r")
+	var status strings.Builder
+	if len(m.list) > 0 {
+		fmt.Fprintf(&status, "(%d/%d)", m.cursor+1, len(m.list))
+	} else {
+		status.WriteString("(no match)")
+	}
+	if m.filter != "" {
+		fmt.Fprintf(&status, " filter: %s", m.filter)
+	}
+	statusText, statusWidth := truncateWidth(status.String(), width-1)
+	m.Out.WriteString(statusText)
+	if len(m.list) > 0 {
+		if d := descriptionOf(m.list[m.cursor]); d != "" && statusWidth+3 < width {
+			d, _ = truncateWidth(strings.Join(strings.Fields(d), " "), width-statusWidth-3)
+			m.Out.WriteString(" ")
+			m.Out.WriteString(DescriptionColor)
+			m.Out.WriteString(d)
+			m.Out.WriteString(ansiReset)
+		}
+	}
+	m.Out.WriteString(menuEraseLine)
+	m.Out.WriteString(menuEraseBelow)
+	backToLine(m.Buffer, rows+1)
+}
+
+func (m *menuT) erase() {
+	m.Out.WriteString("\n\r")
+	m.Out.WriteString(menuEraseBelow)
+	backToLine(m.Buffer, 1)
+}
+
+func (m *menuT) setFilter(filter string) {
+	m.filter = filter
+	m.list = filterElements(m.source, filter)
+	m.cursor = 0
+	m.offset = 0
+}
+
+func (m *menuT) move(delta int) {
+	if len(m.list) <= 0 {
+		return
+	}
+	m.cursor = (m.cursor + delta + len(m.list)) % len(m.list)
+}
+
+// menuSelect shows the candidates on the grid and returns the one
+// selected by the cursor keys. When canceled, it returns nil.
+func menuSelect(ctx context.Context, this *readline.Buffer, source []Element) Element {
+	if this.TTY == nil {
+		return nil
+	}
+	m := &menuT{Buffer: this, source: source, list: source}
+	defer m.erase()
+	for {
+		m.draw()
+		m.Out.Flush()
+		key, err := this.GetKey()
+		if err != nil {
+			return nil
+		}
+		switch key {
+		case "\t", "\x1B[B", "\x0E":
+			m.move(1)
+		case "\x1B[Z", "\x1B[A", "\x10":
+			m.move(-1)
+		case "\x1B[C", "\x06":
+			if m.cursor+m.nlines < len(m.list) {
+				m.cursor += m.nlines
+			}
+		case "\x1B[D", "\x02":
+			if m.cursor-m.nlines >= 0 {
+				m.cursor -= m.nlines
+			}
+		case "\r", "\n":
+			if len(m.list) > 0 {
+				return m.list[m.cursor]
+			}
+		case "\x1B", "\x07", "\x03":
+			return nil
+		case "\b", "\x7F":
+			if m.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(m.filter)
+				m.setFilter(m.filter[:len(m.filter)-size])
+			}
+		default:
+			if ch, size := utf8.DecodeRuneInString(key); size == len(key) && ch >= ' ' {
+				m.setFilter(m.filter + key)
+			}
+		}
+	}
+}
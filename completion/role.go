@@ -0,0 +1,100 @@
+package completion
+
+import (
+	"strings"
+
+	"github.com/zetamatta/nyagos/shell"
+)
+
+// Role is the syntactic role of the word to complete.
+type Role int
+
+const (
+	// RoleCommand is the command-name at the top of the statement.
+	RoleCommand Role = iota
+	// RoleArgument is the argument of the command.
+	RoleArgument
+	// RoleRedirect is the filename after `<`, `>` or `>>`.
+	RoleRedirect
+	// RoleVariable is the name of the variable after `%` or `$`.
+	RoleVariable
+	// RoleAlias is the alias-name given to the `alias` command.
+	RoleAlias
+	// RoleOption is the argument starting with `-`.
+	RoleOption
+)
+
+var roleNames = [...]string{
+	RoleCommand:  "command",
+	RoleArgument: "argument",
+	RoleRedirect: "redirect",
+	RoleVariable: "variable",
+	RoleAlias:    "alias",
+	RoleOption:   "option",
+}
+
+func (r Role) String() string {
+	if r >= 0 && int(r) < len(roleNames) {
+		return roleNames[r]
+	}
+	return "unknown"
+}
+
+// wordContext is the word before the cursor and its role found by the shell tokenizer.
+type wordContext struct {
+	Role  Role
+	Text  string // raw text of the word
+	Pos   int    // byte offset where the word starts
+	Quote rune   // the quotation mark not closed yet, or 0
+}
+
+// lookUpWordContext tokenizes the text before the cursor and
+// decides the role of the last word (except for RoleVariable).
+func lookUpWordContext(left string) *wordContext {
+	tokens := shell.Tokenize(left)
+	wc := &wordContext{Pos: len(left)}
+	if n := len(tokens); n > 0 && tokens[n-1].Kind == shell.TokenWord && tokens[n-1].End == len(left) {
+		last := tokens[n-1]
+		wc.Text = last.Text
+		wc.Pos = last.Pos
+		if last.Quote != shell.NOTQUOTED {
+			wc.Quote = last.Quote
+		}
+		tokens = tokens[:n-1]
+	}
+	if n := len(tokens); n > 0 && tokens[n-1].Kind == shell.TokenRedirect {
+		wc.Role = RoleRedirect
+		return wc
+	}
+	args := []string{}
+	for i, t := range tokens {
+		switch {
+		case t.Kind == shell.TokenTerm:
+			args = args[:0]
+		case t.Kind == shell.TokenWord && (i <= 0 || tokens[i-1].Kind != shell.TokenRedirect):
+			args = append(args, strings.Replace(t.Text, `"`, ``, -1))
+		}
+	}
+	switch {
+	case len(args) <= 0:
+		wc.Role = RoleCommand
+	case len(args) == 1 && strings.EqualFold(args[0], "alias") && AliasLister != nil:
+		wc.Role = RoleAlias
+	case strings.HasPrefix(wc.Text, "-"):
+		wc.Role = RoleOption
+	default:
+		wc.Role = RoleArgument
+	}
+	return wc
+}
+
+// isVariableWord returns true when the word ends with the name of
+// the variable being typed: `%NAME`, `$NAME` or `${NAME`
+func isVariableWord(word string, quote rune) bool {
+	if quote == '\'' {
+		return false
+	}
+	return strings.Count(word, "%")%2 == 1 ||
+		rxDollar.MatchString(word) ||
+		rxDollar2.MatchString(word)
+}
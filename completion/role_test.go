@@ -0,0 +1,57 @@
+package completion
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLookUpWordContext(t *testing.T) {
+	AliasLister = func(context.Context) ([]Element, error) { return nil, nil }
+	defer func() { AliasLister = nil }()
+
+	tests := []struct {
+		left string
+		role Role
+		text string
+	}{
+		{``, RoleCommand, ``},
+		{`gi`, RoleCommand, `gi`},
+		{`ls -l | gr`, RoleCommand, `gr`},
+		{`ls -l ; `, RoleCommand, ``},
+		{`ls foo`, RoleArgument, `foo`},
+		{`ls --co`, RoleOption, `--co`},
+		{`echo a >`, RoleRedirect, ``},
+		{`echo a >> out`, RoleRedirect, `out`},
+		{`sort <in`, RoleRedirect, `in`},
+		{`make 2>err foo`, RoleArgument, `foo`},
+		{`>out ec`, RoleCommand, `ec`},
+		{`alias l`, RoleAlias, `l`},
+		{`echo "a | b`, RoleArgument, `"a | b`},
+	}
+	for _, test := range tests {
+		wc := lookUpWordContext(test.left)
+		if wc.Role != test.role || wc.Text != test.text {
+			t.Fatalf("lookUpWordContext(%q) = (%s,%q), expected (%s,%q)",
+				test.left, wc.Role, wc.Text, test.role, test.text)
+		}
+	}
+	if wc := lookUpWordContext(`type "C:\Program`); wc.Quote != '"' {
+		t.Fatalf("Quote = %q, expected '\"'", wc.Quote)
+	}
+}
+
+func TestIsVariableWord(t *testing.T) {
+	for _, word := range []string{`%VA`, `foo%VA`, `%PATH%;%VA`, `$HO`, `${HO`} {
+		if !isVariableWord(word, 0) {
+			t.Fatalf("isVariableWord(%q) should be true", word)
+		}
+	}
+	for _, word := range []string{`VA`, `%PATH%`, `100`} {
+		if isVariableWord(word, 0) {
+			t.Fatalf("isVariableWord(%q) should be false", word)
+		}
+	}
+	if isVariableWord(`'%VA`, '\'') {
+		t.Fatal("isVariableWord in single quotations should be false")
+	}
+}
@@ -0,0 +1,307 @@
+package completion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Spec is the declarative description of a command-line for completion.
+type Spec struct {
+	Name        string      `json:"name"`
+	Aliases     []string    `json:"aliases,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Subcommands []*Spec     `json:"subcommands,omitempty"`
+	Flags       []*FlagSpec `json:"flags,omitempty"`
+	Args        []*ArgSpec  `json:"args,omitempty"`
+	// Exclusive has groups of flags which can not be used together.
+	Exclusive [][]string `json:"exclusive,omitempty"`
+}
+
+// FlagSpec describes one option of the command.
+type FlagSpec struct {
+	Short       string   `json:"short,omitempty"`
+	Long        string   `json:"long,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Arg         *ArgSpec `json:"arg,omitempty"`
+	Repeat      bool     `json:"repeat,omitempty"`
+}
+
+// ArgSpec describes the kind of a positional argument or a flag's value.
+type ArgSpec struct {
+	// Kind is one of "file", "dir", "command", "branch", "env", "process"
+	// and "list".
+	Kind   string   `json:"kind"`
+	Values []string `json:"values,omitempty"`
+	// Repeat means the argument can be given many times.
+	Repeat bool `json:"repeat,omitempty"`
+}
+
+// ParseSpec reads a Spec from JSON text.
+func ParseSpec(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	if spec.Name == "" {
+		return nil, fmt.Errorf("completion spec: name is not set")
+	}
+	return &spec, nil
+}
+
+// String returns the name to show in the list of completers.
+func (s *Spec) String() string {
+	return fmt.Sprintf("Spec for `%s`", s.Name)
+}
+
+// Register sets the spec into CustomCompletion for its name and aliases.
+func (s *Spec) Register() {
+	for _, name := range append([]string{s.Name}, s.Aliases...) {
+		name = strings.ToLower(name)
+		CustomCompletion[name] = s
+		if filepath.Ext(name) == "" {
+			CustomCompletion[name+".exe"] = s
+		}
+	}
+}
+
+// LoadSpecDir registers all the specs written as `*.json` in dir.
+func LoadSpecDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, file1 := range files {
+		if file1.IsDir() || !strings.EqualFold(filepath.Ext(file1.Name()), ".json") {
+			continue
+		}
+		path1 := filepath.Join(dir, file1.Name())
+		data, err := ioutil.ReadFile(path1)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path1, err)
+			continue
+		}
+		spec, err := ParseSpec(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path1, err)
+			continue
+		}
+		spec.Register()
+	}
+	return nil
+}
+
+func (s *Spec) findSubcommand(name string) *Spec {
+	for _, sub := range s.Subcommands {
+		if strings.EqualFold(sub.Name, name) {
+			return sub
+		}
+		for _, alias := range sub.Aliases {
+			if strings.EqualFold(alias, name) {
+				return sub
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Spec) findFlag(name string) *FlagSpec {
+	for _, f := range s.Flags {
+		if (f.Short != "" && f.Short == name) || (f.Long != "" && f.Long == name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// isExcluded tests whether flag f conflicts with the flags already used.
+func (s *Spec) isExcluded(f *FlagSpec, used map[*FlagSpec]bool) bool {
+	if used[f] && !f.Repeat {
+		return true
+	}
+	for _, group := range s.Exclusive {
+		member := false
+		for _, name := range group {
+			if name == f.Short || name == f.Long {
+				member = true
+				break
+			}
+		}
+		if !member {
+			continue
+		}
+		for _, name := range group {
+			if g := s.findFlag(name); g != nil && g != f && used[g] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+func filterByPrefix(values []string, prefix string) []Element {
+	result := make([]Element, 0, len(values))
+	for _, v := range values {
+		if hasPrefixFold(v, prefix) {
+			result = append(result, Element1(v))
+		}
+	}
+	return result
+}
+
+func listUpBranches(ctx context.Context) []string {
+	out, err := exec.CommandContext(ctx, "git", "for-each-ref",
+		"--format=%(refname:short)", "refs/heads/", "refs/remotes/").Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(out))
+}
+
+func listUpEnvNames() []string {
+	env := os.Environ()
+	result := make([]string, 0, len(env))
+	for _, env1 := range env {
+		if eq := strings.IndexRune(env1, '='); eq > 0 {
+			result = append(result, env1[:eq])
+		}
+	}
+	return result
+}
+
+// complete returns the candidates of word for the kind of argument.
+func (a *ArgSpec) complete(ctx context.Context, word string) ([]Element, error) {
+	switch a.Kind {
+	case "file":
+		return listUpFiles(ctx, word)
+	case "dir":
+		return listUpDirs(ctx, word)
+	case "command":
+		return listUpCommands(ctx, word)
+	case "branch":
+		return filterByPrefix(listUpBranches(ctx), word), nil
+	case "env":
+		return filterByPrefix(listUpEnvNames(), word), nil
+	case "process":
+		return completionProcessName(ctx, []string{word})
+	case "list":
+		return filterByPrefix(a.Values, word), nil
+	}
+	return nil, fmt.Errorf("completion spec: %s: unknown kind", a.Kind)
+}
+
+// addHead inserts head before the text of candidates to complete.
+func addHead(list []Element, head string) []Element {
+	for i, e := range list {
+		list[i] = replaceText(e, head+e.String())
+	}
+	return list
+}
+
+// Complete implements CustomCompleter with the spec.
+func (s *Spec) Complete(ctx context.Context, args []string) ([]Element, error) {
+	if len(args) < 2 {
+		return nil, nil
+	}
+	node := s
+	used := map[*FlagSpec]bool{}
+	var pending *ArgSpec
+	position := 0
+	noMoreFlags := false
+	for _, arg := range args[1 : len(args)-1] {
+		if pending != nil {
+			pending = nil
+			continue
+		}
+		if !noMoreFlags && arg == "--" {
+			noMoreFlags = true
+			continue
+		}
+		if !noMoreFlags && strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			name := arg
+			hasValue := false
+			if eq := strings.IndexRune(arg, '='); eq > 0 {
+				name = arg[:eq]
+				hasValue = true
+			}
+			if f := node.findFlag(name); f != nil {
+				used[f] = true
+				if f.Arg != nil && !hasValue {
+					pending = f.Arg
+				}
+			}
+			continue
+		}
+		if position == 0 {
+			if sub := node.findSubcommand(arg); sub != nil {
+				node = sub
+				used = map[*FlagSpec]bool{}
+				continue
+			}
+		}
+		position++
+	}
+	word := args[len(args)-1]
+	if pending != nil {
+		return pending.complete(ctx, word)
+	}
+	if !noMoreFlags && strings.HasPrefix(word, "-") {
+		if eq := strings.IndexRune(word, '='); eq > 0 {
+			if f := node.findFlag(word[:eq]); f != nil && f.Arg != nil {
+				list, err := f.Arg.complete(ctx, word[eq+1:])
+				return addHead(list, word[:eq+1]), err
+			}
+			return nil, nil
+		}
+		result := []Element{}
+		for _, f := range node.Flags {
+			if node.isExcluded(f, used) {
+				continue
+			}
+			for _, name := range []string{f.Short, f.Long} {
+				if name != "" && strings.HasPrefix(name, word) {
+					result = append(result, Element3{name, name, f.Description})
+				}
+			}
+		}
+		return result, nil
+	}
+	result := []Element{}
+	if position == 0 {
+		for _, sub := range node.Subcommands {
+			if hasPrefixFold(sub.Name, word) {
+				result = append(result, Element3{sub.Name, sub.Name, sub.Description})
+			}
+		}
+	}
+	var arg *ArgSpec
+	if position < len(node.Args) {
+		arg = node.Args[position]
+	} else if n := len(node.Args); n > 0 && node.Args[n-1].Repeat {
+		arg = node.Args[n-1]
+	}
+	if arg != nil {
+		list, err := arg.complete(ctx, word)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, list...)
+	}
+	if len(result) <= 0 {
+		// fall back to the default filename completion.
+		return nil, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package completion
+
+import (
+	"context"
+	"testing"
+)
+
+const testSpec = `{
+	"name": "tool",
+	"flags": [
+		{"short": "-v", "long": "--verbose", "description": "print more"},
+		{"short": "-q", "long": "--quiet"},
+		{"long": "--color", "arg": {"kind": "list", "values": ["always", "never", "auto"]}}
+	],
+	"exclusive": [["-v", "--verbose", "-q", "--quiet"]],
+	"subcommands": [
+		{"name": "start", "args": [{"kind": "list", "values": ["alpha", "beta"]}]},
+		{"name": "stop"}
+	]
+}`
+
+func completeSpec(t *testing.T, spec *Spec, args ...string) []string {
+	t.Helper()
+	list, err := spec.Complete(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toComplete(list)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpecComplete(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args   []string
+		expect []string
+	}{
+		{[]string{"tool", "st"}, []string{"start", "stop"}},
+		{[]string{"tool", "--c"}, []string{"--color"}},
+		{[]string{"tool", "--color", "a"}, []string{"always", "auto"}},
+		{[]string{"tool", "--color=n"}, []string{"--color=never"}},
+		{[]string{"tool", "-v", "-"}, []string{"--color"}},
+		{[]string{"tool", "start", "b"}, []string{"beta"}},
+		{[]string{"tool", "-q", "start", ""}, []string{"alpha", "beta"}},
+	}
+	for _, test := range tests {
+		result := completeSpec(t, spec, test.args...)
+		if !equalStrings(result, test.expect) {
+			t.Errorf("%v: %v != %v", test.args, result, test.expect)
+		}
+	}
+}
+
+func TestSpecDescription(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := spec.Complete(context.Background(), []string{"tool", "--v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || descriptionOf(list[0]) != "print more" {
+		t.Fatalf("%v", list)
+	}
+}
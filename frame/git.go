@@ -0,0 +1,244 @@
+package frame
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitDirtyMark is appended to the branch name by the prompt segment `git`
+// when tracked files are modified.
+var GitDirtyMark = "*"
+
+// findGitDir returns the .git directory and the top of the working tree
+// containing dir.
+func findGitDir(dir string) (string, string, bool) {
+	for {
+		gitPath := filepath.Join(dir, ".git")
+		if stat, err := os.Stat(gitPath); err == nil {
+			if stat.IsDir() {
+				return gitPath, dir, true
+			}
+			// `gitdir: PATH` for worktrees and submodules
+			if data, err := ioutil.ReadFile(gitPath); err == nil {
+				text := strings.TrimSpace(string(data))
+				if strings.HasPrefix(text, "gitdir:") {
+					gitDir := strings.TrimSpace(text[7:])
+					if !filepath.IsAbs(gitDir) {
+						gitDir = filepath.Join(dir, gitDir)
+					}
+					return gitDir, dir, true
+				}
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// gitBranch returns the branch name checked out or the short hash of HEAD.
+func gitBranch(gitDir string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+	head := strings.TrimSpace(string(data))
+	if strings.HasPrefix(head, "ref:") {
+		ref := strings.TrimSpace(head[4:])
+		return strings.TrimPrefix(ref, "refs/heads/"), nil
+	}
+	if len(head) > 7 {
+		head = head[:7]
+	}
+	return head, nil
+}
+
+type gitIndexEntry struct {
+	mtimeSec  uint32
+	mtimeNsec uint32
+	mode      uint32
+	size      uint32
+	hash      []byte
+	stage     int
+	skip      bool // skip-worktree or assume-valid
+	path      string
+}
+
+var errBadGitIndex = errors.New("git index: broken")
+
+// readGitIndex parses the index file of version 2, 3 and 4.
+func readGitIndex(data []byte) ([]gitIndexEntry, error) {
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errBadGitIndex
+	}
+	version := binary.BigEndian.Uint32(data[4:])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("git index: version %d is not supported", version)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:]))
+	entries := make([]gitIndexEntry, 0, count)
+	pos := 12
+	prevPath := ""
+	for i := 0; i < count; i++ {
+		start := pos
+		if pos+62 > len(data) {
+			return nil, errBadGitIndex
+		}
+		e := gitIndexEntry{
+			mtimeSec:  binary.BigEndian.Uint32(data[pos+8:]),
+			mtimeNsec: binary.BigEndian.Uint32(data[pos+12:]),
+			mode:      binary.BigEndian.Uint32(data[pos+24:]),
+			size:      binary.BigEndian.Uint32(data[pos+36:]),
+			hash:      data[pos+40 : pos+60],
+		}
+		flags := binary.BigEndian.Uint16(data[pos+60:])
+		e.stage = int(flags>>12) & 3
+		e.skip = flags&0x8000 != 0
+		pos += 62
+		if version >= 3 && flags&0x4000 != 0 {
+			if pos+2 > len(data) {
+				return nil, errBadGitIndex
+			}
+			e.skip = e.skip || binary.BigEndian.Uint16(data[pos:])&0x4000 != 0
+			pos += 2
+		}
+		if version == 4 {
+			// the length to remove from the previous path as varint
+			strip := 0
+			for {
+				if pos >= len(data) {
+					return nil, errBadGitIndex
+				}
+				c := data[pos]
+				pos++
+				strip = strip<<7 | int(c&0x7F)
+				if c&0x80 == 0 {
+					break
+				}
+				strip++
+			}
+			if strip > len(prevPath) {
+				return nil, errBadGitIndex
+			}
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, errBadGitIndex
+			}
+			e.path = prevPath[:len(prevPath)-strip] + string(data[pos:pos+end])
+			pos += end + 1
+		} else {
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, errBadGitIndex
+			}
+			e.path = string(data[pos : pos+end])
+			pos += end + 1
+			// padded to the multiple of eight bytes
+			pos = start + (pos-start+7)/8*8
+		}
+		prevPath = e.path
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func gitBlobHash(data []byte) []byte {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// gitModified compares the file with the entry of the index as git does:
+// the file whose size and mtime are same as the index is not modified.
+func gitModified(workTree string, e *gitIndexEntry) bool {
+	const (
+		modeMask    = 0170000
+		modeSymlink = 0120000
+		modeGitlink = 0160000
+	)
+	if e.mode&modeMask == modeGitlink {
+		return false
+	}
+	path := filepath.Join(workTree, filepath.FromSlash(e.path))
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return true
+	}
+	if uint32(stat.Size()) != e.size {
+		return true
+	}
+	mtime := stat.ModTime()
+	if uint32(mtime.Unix()) == e.mtimeSec &&
+		(e.mtimeNsec == 0 || uint32(mtime.Nanosecond()) == e.mtimeNsec) {
+		return false
+	}
+	var data []byte
+	if e.mode&modeMask == modeSymlink {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return true
+		}
+		data = []byte(link)
+	} else if data, err = ioutil.ReadFile(path); err != nil {
+		return true
+	}
+	if bytes.Equal(gitBlobHash(data), e.hash) {
+		return false
+	}
+	// core.autocrlf converts LF in the repository to CRLF.
+	data = bytes.Replace(data, []byte{'\r', '\n'}, []byte{'\n'}, -1)
+	return !bytes.Equal(gitBlobHash(data), e.hash)
+}
+
+// gitDirty returns true when the tracked files are modified, deleted
+// or conflicted.
+func gitDirty(gitDir, workTree string) (bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(gitDir, "index"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	entries, err := readGitIndex(data)
+	if err != nil {
+		return false, err
+	}
+	for i := range entries {
+		e := &entries[i]
+		if e.stage != 0 {
+			return true, nil
+		}
+		if !e.skip && gitModified(workTree, e) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// gitStatus returns the branch of the repository containing dir with
+// GitDirtyMark when the files are modified. It returns "" out of repositories.
+func gitStatus(dir string) string {
+	gitDir, workTree, ok := findGitDir(dir)
+	if !ok {
+		return ""
+	}
+	branch, err := gitBranch(gitDir)
+	if err != nil {
+		return ""
+	}
+	if dirty, err := gitDirty(gitDir, workTree); err == nil && dirty {
+		return branch + GitDirtyMark
+	}
+	return branch
+}
@@ -0,0 +1,89 @@
+package frame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func makeGitIndex(t *testing.T, workTree string, names ...string) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("DIRC")
+	binary.Write(&buffer, binary.BigEndian, uint32(2))
+	binary.Write(&buffer, binary.BigEndian, uint32(len(names)))
+	for _, name := range names {
+		path := filepath.Join(workTree, name)
+		stat, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		start := buffer.Len()
+		mtime := stat.ModTime()
+		fields := []uint32{
+			0, 0,
+			uint32(mtime.Unix()), uint32(mtime.Nanosecond()),
+			0, 0, 0100644, 0, 0,
+			uint32(stat.Size()),
+		}
+		binary.Write(&buffer, binary.BigEndian, fields)
+		buffer.Write(gitBlobHash(data))
+		binary.Write(&buffer, binary.BigEndian, uint16(len(name)))
+		buffer.WriteString(name)
+		buffer.WriteByte(0)
+		for (buffer.Len()-start)%8 != 0 {
+			buffer.WriteByte(0)
+		}
+	}
+	return buffer.Bytes()
+}
+
+func TestGitStatus(t *testing.T) {
+	workTree, err := ioutil.TempDir("", "nyagos-git")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(workTree)
+
+	gitDir := filepath.Join(workTree, ".git")
+	subDir := filepath.Join(workTree, "sub")
+	os.Mkdir(gitDir, 0777)
+	os.Mkdir(subDir, 0777)
+	ioutil.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/topic\n"), 0666)
+	ioutil.WriteFile(filepath.Join(workTree, "a.txt"), []byte("hello\n"), 0666)
+	ioutil.WriteFile(filepath.Join(subDir, "bb.txt"), []byte("world\n"), 0666)
+
+	index := makeGitIndex(t, workTree, "a.txt", "sub/bb.txt")
+	entries, err := readGitIndex(index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 2 || entries[1].path != "sub/bb.txt" {
+		t.Fatalf("readGitIndex: %v", entries)
+	}
+	ioutil.WriteFile(filepath.Join(gitDir, "index"), index, 0666)
+
+	if s := gitStatus(subDir); s != "topic" {
+		t.Fatalf("gitStatus() = `%s`", s)
+	}
+	// touched but not modified
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(workTree, "a.txt"), future, future)
+	if s := gitStatus(workTree); s != "topic" {
+		t.Fatalf("gitStatus() after touch = `%s`", s)
+	}
+	ioutil.WriteFile(filepath.Join(workTree, "a.txt"), []byte("HELLO\n"), 0666)
+	if s := gitStatus(workTree); s != "topic"+GitDirtyMark {
+		t.Fatalf("gitStatus() after modified = `%s`", s)
+	}
+	if s := gitStatus(os.TempDir()); s != "" {
+		t.Fatalf("gitStatus() out of repository = `%s`", s)
+	}
+}
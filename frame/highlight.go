@@ -0,0 +1,177 @@
+package frame
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zetamatta/nyagos/alias"
+	"github.com/zetamatta/nyagos/commands"
+	"github.com/zetamatta/nyagos/shell"
+)
+
+// Escape sequences used by Highlight
+var (
+	HighlightBuiltIn  = "\x1B[35;1m"
+	HighlightAlias    = "\x1B[36;1m"
+	HighlightFunction = "\x1B[36m"
+	HighlightCommand  = "\x1B[32;1m"
+	HighlightNotFound = "\x1B[31;1m"
+	HighlightString   = "\x1B[33m"
+	HighlightVariable = "\x1B[35m"
+	HighlightRedirect = "\x1B[34;1m"
+	HighlightOperator = "\x1B[34;1m"
+)
+
+const highlightReset = "\x1B[0m"
+
+var commandColorCache = map[string]string{}
+
+// ResetHighlightCache forgets the results of looking up commands.
+func ResetHighlightCache() {
+	commandColorCache = map[string]string{}
+}
+
+func commandColor(name string) string {
+	key := strings.ToLower(name)
+	if color, ok := commandColorCache[key]; ok {
+		return color
+	}
+	var color string
+	if a, ok := alias.Table[key]; ok {
+		if _, ok := a.(*alias.Func); ok {
+			color = HighlightAlias
+		} else {
+			color = HighlightFunction
+		}
+	} else if commands.IsBuiltIn(name) || (len(name) == 2 && name[1] == ':') {
+		color = HighlightBuiltIn
+	} else if shell.LookPath(name) != "" {
+		color = HighlightCommand
+	} else {
+		color = HighlightNotFound
+	}
+	commandColorCache[key] = color
+	return color
+}
+
+type highlighter struct {
+	buffer           strings.Builder
+	word             strings.Builder
+	isTop            bool
+	isRedirectTarget bool
+}
+
+func (h *highlighter) flushWord() {
+	if h.word.Len() <= 0 {
+		return
+	}
+	word := h.word.String()
+	h.word.Reset()
+	if h.isRedirectTarget {
+		h.isRedirectTarget = false
+	} else if h.isTop {
+		name := strings.Replace(word, `"`, ``, -1)
+		if !strings.ContainsAny(name, "%$") {
+			h.buffer.WriteString(commandColor(name))
+			h.buffer.WriteString(word)
+			h.buffer.WriteString(highlightReset)
+			h.isTop = false
+			return
+		}
+		h.isTop = false
+	}
+	h.buffer.WriteString(word)
+}
+
+func (h *highlighter) writeColored(color, text string) {
+	h.buffer.WriteString(color)
+	h.buffer.WriteString(text)
+	h.buffer.WriteString(highlightReset)
+}
+
+// Highlight inserts escape sequences into the command-line to colorize
+// command-names, strings, variables, redirections and operators.
+func Highlight(line string) string {
+	h := &highlighter{isTop: true}
+	runes := []rune(line)
+	lastchar := ' '
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch {
+		case ch == '"' || ch == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != ch {
+				j++
+			}
+			if j < len(runes) {
+				j++
+			}
+			if h.isTop {
+				h.word.WriteString(string(runes[i:j]))
+			} else {
+				h.flushWord()
+				h.writeColored(HighlightString, string(runes[i:j]))
+			}
+			i = j - 1
+		case ch == '%':
+			j := i + 1
+			for j < len(runes) && runes[j] != '%' && !unicode.IsSpace(runes[j]) {
+				j++
+			}
+			if j < len(runes) && runes[j] == '%' {
+				h.flushWord()
+				h.writeColored(HighlightVariable, string(runes[i:j+1]))
+				i = j
+			} else {
+				h.word.WriteRune(ch)
+			}
+		case ch == '$' && i+1 < len(runes) && (runes[i+1] == '{' || unicode.IsLetter(runes[i+1]) || runes[i+1] == '_'):
+			j := i + 1
+			if runes[j] == '{' {
+				for j < len(runes) && runes[j] != '}' {
+					j++
+				}
+				if j < len(runes) {
+					j++
+				}
+			} else {
+				for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+					j++
+				}
+			}
+			h.flushWord()
+			h.writeColored(HighlightVariable, string(runes[i:j]))
+			i = j - 1
+		case unicode.IsSpace(ch):
+			h.flushWord()
+			h.buffer.WriteRune(ch)
+		case ch == '|' || ch == '&' || (ch == ';' && unicode.IsSpace(lastchar)):
+			h.flushWord()
+			j := i + 1
+			for j < len(runes) && (runes[j] == '|' || runes[j] == '&') {
+				j++
+			}
+			h.writeColored(HighlightOperator, string(runes[i:j]))
+			h.isTop = true
+			i = j - 1
+		case ch == '<' || ch == '>' ||
+			((ch == '1' || ch == '2') && h.word.Len() <= 0 && i+1 < len(runes) && runes[i+1] == '>'):
+			h.flushWord()
+			j := i + 1
+			for j < len(runes) && strings.ContainsRune("<>&!|12", runes[j]) {
+				if (runes[j] == '1' || runes[j] == '2') && runes[j-1] != '&' {
+					break
+				}
+				j++
+			}
+			h.writeColored(HighlightRedirect, string(runes[i:j]))
+			h.isRedirectTarget = !unicode.IsDigit(runes[j-1])
+			i = j - 1
+		default:
+			h.word.WriteRune(ch)
+		}
+		lastchar = runes[i]
+	}
+	h.flushWord()
+	return h.buffer.String()
+}
@@ -9,7 +9,9 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/zetamatta/nyagos/completion"
 	"github.com/zetamatta/nyagos/nodos"
+	"github.com/zetamatta/nyagos/plugins"
 )
 
 var Version string
@@ -31,6 +33,11 @@ func LoadScripts(shellEngine func(string) error,
 	}
 	exeFolder := filepath.Dir(exeName)
 	nyagos_d := filepath.Join(exeFolder, "nyagos.d")
+	for _, dir := range []string{filepath.Join(nyagos_d, "complete"), filepath.Join(AppDataDir(), "complete")} {
+		if err := completion.LoadSpecDir(dir); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}
 	files, err := ioutil.ReadDir(nyagos_d)
 	if err == nil {
 		for _, finfo1 := range files {
@@ -49,6 +56,8 @@ func LoadScripts(shellEngine func(string) error,
 			}
 		}
 	}
+	plugins.Load(shellEngine, langEngine, os.Stderr)
+
 	fname := filepath.Join(exeFolder, ".nyagos")
 	if _, err := os.Stat(fname); err == nil {
 		if _, err := langEngine(fname); err != nil {
@@ -65,26 +74,13 @@ func LoadScripts(shellEngine func(string) error,
 
 func dotNyagos(langEngine func(string) ([]byte, error)) error {
 	dot_nyagos := filepath.Join(nodos.GetHome(), ".nyagos")
-	dotStat, err := os.Stat(dot_nyagos)
-	if err != nil {
+	if _, err := os.Stat(dot_nyagos); err != nil {
 		return nil
 	}
-	cachePath := filepath.Join(AppDataDir(), runtime.GOARCH+".nyagos.luac")
-	cacheStat, err := os.Stat(cachePath)
-	if err == nil {
-		if cacheStat.Size() != 0 && !dotStat.ModTime().After(cacheStat.ModTime()) {
-			_, err = langEngine(cachePath)
-			if err == nil {
-				return nil
-			}
-		}
-		os.Remove(cachePath)
-	}
-	chank, err := langEngine(dot_nyagos)
-	if err != nil || chank == nil {
-		return err
-	}
-	return ioutil.WriteFile(cachePath, chank, os.FileMode(0644))
+	// The compiled chunk is cached by langEngine now.
+	os.Remove(filepath.Join(AppDataDir(), runtime.GOARCH+".nyagos.luac"))
+	_, err := langEngine(dot_nyagos)
+	return err
 }
 
 func barNyagos(shellEngine func(string) error, folder string) {
@@ -7,18 +7,57 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/zetamatta/nyagos/alias"
 	"github.com/zetamatta/nyagos/commands"
 	"github.com/zetamatta/nyagos/completion"
 	"github.com/zetamatta/nyagos/history"
 	"github.com/zetamatta/nyagos/nodos"
+	"github.com/zetamatta/nyagos/plugins"
 	"github.com/zetamatta/nyagos/shell"
 )
 
 var DefaultHistory *history.Container
 
+// Suggest returns the newest history which starts with line
+// preferring ones executed on the current directory.
+func Suggest(line string) string {
+	if DefaultHistory == nil {
+		return ""
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = ""
+	}
+	return DefaultHistory.Suggest(line, wd)
+}
+
+// CommandUsage returns when the commands in the history were executed last.
+func CommandUsage() map[string]time.Time {
+	if DefaultHistory == nil {
+		return nil
+	}
+	result := map[string]time.Time{}
+	for i := DefaultHistory.Len() - 1; i >= 0; i-- {
+		row := DefaultHistory.Get(i)
+		if row.Stamp.IsZero() {
+			continue
+		}
+		fields := strings.Fields(row.Text)
+		if len(fields) <= 0 {
+			continue
+		}
+		if _, ok := result[fields[0]]; !ok {
+			result[fields[0]] = row.Stamp
+		}
+	}
+	return result
+}
+
 func Start(mainHandler func() error) error {
 	defer PanicHandler()
 
@@ -28,6 +67,14 @@ func Start(mainHandler func() error) error {
 	})
 	completion.AppendCommandLister(commands.AllNames)
 	completion.AppendCommandLister(alias.AllNames)
+	completion.AliasLister = alias.AllNames
+	completion.CommandUsage = CommandUsage
+	completion.HelpCachePath = filepath.Join(AppDataDir(), "nyagos.helpcache")
+	commands.DirHistoryPath = filepath.Join(AppDataDir(), "nyagos.dirs")
+	plugins.Dir = filepath.Join(AppDataDir(), "plugins")
+	if exeName, err := os.Executable(); err == nil {
+		plugins.CatalogDir = filepath.Join(filepath.Dir(exeName), "nyagos.d", "catalog")
+	}
 
 	nodos.CoInitializeEx(0, nodos.COINIT_MULTITHREADED)
 	defer nodos.CoUninitialize()
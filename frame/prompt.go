@@ -3,11 +3,13 @@ package frame
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 	"unicode"
 
 	"github.com/zetamatta/nyagos/nodos"
+	"github.com/zetamatta/nyagos/readline"
 )
 
 // Format2Prompt converts format-string to output-string
@@ -15,6 +17,41 @@ func Format2Prompt(format string) string {
 	if format == "" {
 		format = "[$P]$_$$$S"
 	}
+	result := format2prompt(format)
+	setPromptLines(strings.Count(result, "\n"))
+	return result
+}
+
+// formatLinePrompt converts format-string to one line and returns its width.
+func formatLinePrompt(format string) (string, int) {
+	if format == "" {
+		return "", 0
+	}
+	text := format2prompt(format)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text, readline.GetStringWidth(rxAnsiEscCode.ReplaceAllString(text, ""))
+}
+
+var rxAnsiEscCode = regexp.MustCompile("\x1b[^a-zA-Z]*[a-zA-Z]")
+
+// RightPrompt converts the format of the right prompt (%RPROMPT%)
+// and returns its width.
+func RightPrompt(format string) (string, int) {
+	return formatLinePrompt(format)
+}
+
+// TransientPrompt converts the format of the prompt (%TRANSIENT_PROMPT%)
+// which replaces the prompt of the line accepted, and returns its width.
+func TransientPrompt(format string) (string, int) {
+	if format == "" {
+		format = "$$$S"
+	}
+	return formatLinePrompt(format)
+}
+
+func format2prompt(format string) string {
 	var buffer strings.Builder
 	lastchar := '\000'
 	for reader := strings.NewReader(format); reader.Len() > 0; {
@@ -84,6 +121,26 @@ func Format2Prompt(format string) string {
 				}
 			} else if c == 'v' {
 				// Windows Version
+			} else if c == '{' {
+				// ${NAME}: the prompt segment
+				if content, ok := readPromptBlock(reader, '{', '}'); ok {
+					buffer.WriteString(expandSegment(content))
+				} else {
+					buffer.WriteString("${")
+					buffer.WriteString(content)
+				}
+			} else if c == '[' {
+				// $[SPEC]: the color and the style
+				content, ok := readPromptBlock(reader, '[', ']')
+				if seq, ok2 := promptStyle(content); ok && ok2 {
+					buffer.WriteString(seq)
+				} else {
+					buffer.WriteString("$[")
+					buffer.WriteString(content)
+					if ok {
+						buffer.WriteRune(']')
+					}
+				}
 			} else if c == '_' {
 				buffer.WriteRune('\n')
 			} else if c == '$' {
@@ -100,3 +157,61 @@ func Format2Prompt(format string) string {
 	}
 	return buffer.String()
 }
+
+// readPromptBlock reads the text until the close bracket corresponding to
+// the open one already read. It returns false when the bracket is not closed.
+func readPromptBlock(reader *strings.Reader, open, close rune) (string, bool) {
+	var buffer strings.Builder
+	depth := 0
+	for reader.Len() > 0 {
+		ch, _, _ := reader.ReadRune()
+		if ch == close {
+			if depth <= 0 {
+				return buffer.String(), true
+			}
+			depth--
+		} else if ch == open {
+			depth++
+		}
+		buffer.WriteRune(ch)
+	}
+	return buffer.String(), false
+}
+
+// splitPromptBlock splits text at the first sep out of `${...}` and `$[...]`
+func splitPromptBlock(text string, sep rune) (string, string, bool) {
+	depth := 0
+	for i, ch := range text {
+		switch ch {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case sep:
+			if depth <= 0 {
+				return text[:i], text[i+1:], true
+			}
+		}
+	}
+	return text, "", false
+}
+
+// expandSegment expands the content of `${...}`. `${NAME}` and `${NAME:ARG}`
+// are replaced with the text of the segment. `${NAME?THEN:ELSE}` is replaced
+// with THEN when the text is neither "" nor "0", otherwise with ELSE.
+func expandSegment(content string) string {
+	head, body, conditional := splitPromptBlock(content, '?')
+	name, arg, _ := splitPromptBlock(head, ':')
+	text, ok := promptSegment(name, arg)
+	if !ok {
+		return "${" + content + "}"
+	}
+	if !conditional {
+		return text
+	}
+	thenPart, elsePart, _ := splitPromptBlock(body, ':')
+	if text != "" && text != "0" {
+		return format2prompt(thenPart)
+	}
+	return format2prompt(elsePart)
+}
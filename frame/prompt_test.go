@@ -0,0 +1,66 @@
+package frame
+
+import (
+	"testing"
+
+	"github.com/zetamatta/nyagos/shell"
+)
+
+func TestPromptStyle(t *testing.T) {
+	tests := []struct {
+		format string
+		expect string
+	}{
+		{"$[red]x$[reset]", "\x1B[31mx\x1B[0m"},
+		{"$[bold,bright_blue]", "\x1B[1;94m"},
+		{"$[208 bg:#ff8800]", "\x1B[38;5;208;48;2;255;136;0m"},
+		{"$[#0f0]", "\x1B[38;2;0;255;0m"},
+		{"$[nocolor]", "$[nocolor]"},
+		{"$[red", "$[red"},
+	}
+	for _, p := range tests {
+		if result := format2prompt(p.format); result != p.expect {
+			t.Errorf("format2prompt(%q) = %q (expected %q)", p.format, result, p.expect)
+		}
+	}
+}
+
+func TestPromptConditional(t *testing.T) {
+	save := shell.LastErrorLevel
+	defer func() { shell.LastErrorLevel = save }()
+
+	format := "${status?$[red][${status}]$[reset]:ok}$$"
+	shell.LastErrorLevel = 0
+	ResetPromptSegments()
+	if result := format2prompt(format); result != "ok$" {
+		t.Errorf("status=0: %q", result)
+	}
+	shell.LastErrorLevel = 2
+	ResetPromptSegments()
+	if result := format2prompt(format); result != "\x1B[31m[2]\x1B[0m$" {
+		t.Errorf("status=2: %q", result)
+	}
+	if result := format2prompt("${nosuchsegment?x}"); result != "${nosuchsegment?x}" {
+		t.Errorf("unknown segment: %q", result)
+	}
+}
+
+func TestShortenPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		n      int
+		expect string
+	}{
+		{"~/a/b/c", 2, "b/c"},
+		{"~/a", 2, "~/a"},
+		{"/a/b", 2, "/a/b"},
+		{"C:/a/b", 2, "C:/a/b"},
+		{"C:/a/b/c", 2, "b/c"},
+		{"~/a/b", 0, "~/a/b"},
+	}
+	for _, p := range tests {
+		if result := shortenPath(p.path, p.n); result != p.expect {
+			t.Errorf("shortenPath(%q,%d) = %q (expected %q)", p.path, p.n, result, p.expect)
+		}
+	}
+}
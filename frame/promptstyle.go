@@ -0,0 +1,100 @@
+package frame
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var promptColorNames = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+var promptStyleNames = map[string]string{
+	"reset":     "0",
+	"none":      "0",
+	"bold":      "1",
+	"dim":       "2",
+	"italic":    "3",
+	"underline": "4",
+	"blink":     "5",
+	"reverse":   "7",
+	"strike":    "9",
+}
+
+// promptColor converts the name of a color to the parameters of SGR.
+// base is 30 for the foreground and 40 for the background.
+func promptColor(name string, base int) (string, bool) {
+	if name == "default" {
+		return strconv.Itoa(base + 9), true
+	}
+	if n, ok := promptColorNames[name]; ok {
+		return strconv.Itoa(base + n), true
+	}
+	if strings.HasPrefix(name, "bright_") {
+		if n, ok := promptColorNames[name[7:]]; ok {
+			return strconv.Itoa(base + 60 + n), true
+		}
+		return "", false
+	}
+	if strings.HasPrefix(name, "#") {
+		// truecolor: #RRGGBB or #RGB
+		hex := name[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return "", false
+		}
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8,
+			(rgb>>16)&0xFF, (rgb>>8)&0xFF, rgb&0xFF), true
+	}
+	// 256 colors
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n <= 255 {
+		return fmt.Sprintf("%d;5;%d", base+8, n), true
+	}
+	return "", false
+}
+
+// promptStyle converts `$[SPEC]` to the escape sequence.
+// SPEC is the list of the styles and colors separated by commas or spaces:
+// `red`, `bright_blue`, `208`, `#FF8800`, `bg:blue`, `bold`, `reset` ...
+func promptStyle(spec string) (string, bool) {
+	fields := strings.FieldsFunc(spec, func(c rune) bool {
+		return c == ',' || c == ';' || c == ' '
+	})
+	if len(fields) <= 0 {
+		return "", false
+	}
+	params := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.ToLower(field)
+		if p, ok := promptStyleNames[field]; ok {
+			params = append(params, p)
+		} else if strings.HasPrefix(field, "bg:") {
+			p, ok := promptColor(field[3:], 40)
+			if !ok {
+				return "", false
+			}
+			params = append(params, p)
+		} else {
+			p, ok := promptColor(strings.TrimPrefix(field, "fg:"), 30)
+			if !ok {
+				return "", false
+			}
+			params = append(params, p)
+		}
+	}
+	return "\x1B[" + strings.Join(params, ";") + "m", true
+}
@@ -0,0 +1,270 @@
+package frame
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zetamatta/nyagos/nodos"
+	"github.com/zetamatta/nyagos/shell"
+)
+
+// The prompt segment `${NAME}` in %PROMPT% is replaced with the text
+// returned by the provider registered with RegisterPromptSegment.
+// The providers run in goroutines. When one does not finish within
+// PromptSegmentWait, SegmentPlaceholder is shown instead and the prompt
+// is repainted when it finishes.
+
+// PromptSegmentWait is the time to wait for the providers before
+// showing the prompt with placeholders.
+var PromptSegmentWait = 30 * time.Millisecond
+
+// SegmentPlaceholder is shown while the provider is running.
+var SegmentPlaceholder = "..."
+
+// LastDuration is the time which the last command-line took.
+// The providers running in goroutines do not read it but the copy
+// taken by ResetPromptSegments.
+var LastDuration time.Duration
+
+// OnPromptSegmentDone is called when the provider which has been shown
+// as the placeholder finishes.
+var OnPromptSegmentDone func()
+
+type segmentResult struct {
+	text     string
+	finished bool
+	late     bool // the placeholder has been shown
+	done     chan struct{}
+}
+
+var (
+	segmentMutex     sync.Mutex
+	segmentProviders = map[string]func(context.Context, string) string{}
+	segmentResults   = map[string]*segmentResult{}
+	segmentCtx       context.Context
+	segmentCancel    func()
+	promptLines      int
+
+	// the values of the last command-line when the prompt started
+	segmentStatus   int
+	segmentDuration time.Duration
+)
+
+// RegisterPromptSegment sets the provider of the prompt segment `${name}`.
+// When f is nil, the segment is removed.
+func RegisterPromptSegment(name string, f func(context.Context) string) {
+	if f == nil {
+		registerPromptSegment(name, nil)
+		return
+	}
+	registerPromptSegment(name, func(ctx context.Context, _ string) string {
+		return f(ctx)
+	})
+}
+
+// registerPromptSegment sets the provider receiving ARG of `${name:ARG}`.
+func registerPromptSegment(name string, f func(context.Context, string) string) {
+	segmentMutex.Lock()
+	defer segmentMutex.Unlock()
+	name = strings.ToLower(name)
+	if f == nil {
+		delete(segmentProviders, name)
+	} else {
+		segmentProviders[name] = f
+	}
+}
+
+// ResetPromptSegments forgets the texts of the segments to call
+// the providers again on the next prompt. The providers still running
+// are canceled.
+func ResetPromptSegments() {
+	segmentMutex.Lock()
+	defer segmentMutex.Unlock()
+	if segmentCancel != nil {
+		segmentCancel()
+	}
+	segmentCtx, segmentCancel = context.WithCancel(context.Background())
+	segmentResults = map[string]*segmentResult{}
+	segmentStatus = shell.LastErrorLevel
+	segmentDuration = LastDuration
+}
+
+// lastCommandStatus returns the exit code and the duration of the last
+// command-line taken when the prompt started.
+func lastCommandStatus() (int, time.Duration) {
+	segmentMutex.Lock()
+	defer segmentMutex.Unlock()
+	return segmentStatus, segmentDuration
+}
+
+// PromptLines returns the number of the newlines in the prompt formatted last.
+func PromptLines() int {
+	segmentMutex.Lock()
+	defer segmentMutex.Unlock()
+	return promptLines
+}
+
+func setPromptLines(n int) {
+	segmentMutex.Lock()
+	promptLines = n
+	segmentMutex.Unlock()
+}
+
+// promptSegment returns the text of the segment. When the segment does not
+// exist, it returns false.
+func promptSegment(name, arg string) (string, bool) {
+	segmentMutex.Lock()
+	name = strings.ToLower(name)
+	f, ok := segmentProviders[name]
+	if !ok {
+		segmentMutex.Unlock()
+		return "", false
+	}
+	if segmentCtx == nil {
+		segmentCtx, segmentCancel = context.WithCancel(context.Background())
+	}
+	key := name + ":" + arg
+	r, ok := segmentResults[key]
+	if !ok {
+		r = &segmentResult{done: make(chan struct{})}
+		segmentResults[key] = r
+		go func(ctx context.Context) {
+			text := f(ctx, arg)
+			segmentMutex.Lock()
+			r.text = text
+			r.finished = true
+			late := r.late
+			segmentMutex.Unlock()
+			close(r.done)
+			if late && ctx.Err() == nil && OnPromptSegmentDone != nil {
+				OnPromptSegmentDone()
+			}
+		}(segmentCtx)
+	}
+	segmentMutex.Unlock()
+
+	timer := time.NewTimer(PromptSegmentWait)
+	defer timer.Stop()
+	select {
+	case <-r.done:
+	case <-timer.C:
+	}
+	segmentMutex.Lock()
+	defer segmentMutex.Unlock()
+	if r.finished {
+		return r.text, true
+	}
+	r.late = true
+	return SegmentPlaceholder, true
+}
+
+// formatDuration rounds d to be shown in the prompt: `15ms`, `1.2s`, `3m4s`
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+func init() {
+	RegisterPromptSegment("status", func(context.Context) string {
+		status, _ := lastCommandStatus()
+		return strconv.Itoa(status)
+	})
+	RegisterPromptSegment("duration", func(context.Context) string {
+		_, d := lastCommandStatus()
+		if d <= 0 {
+			return ""
+		}
+		return formatDuration(d)
+	})
+	RegisterPromptSegment("git", func(context.Context) string {
+		wd, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		return gitStatus(wd)
+	})
+	RegisterPromptSegment("user", func(context.Context) string {
+		return userName()
+	})
+	RegisterPromptSegment("host", func(context.Context) string {
+		return hostName()
+	})
+	RegisterPromptSegment("jobs", func(context.Context) string {
+		return strconv.Itoa(shell.BackgroundJobs())
+	})
+	registerPromptSegment("path", func(_ context.Context, arg string) string {
+		wd, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		n, _ := strconv.Atoi(arg)
+		return shortenPath(nodos.ReplaceHomeToTildeSlash(wd), n)
+	})
+}
+
+var (
+	userNameOnce sync.Once
+	userNameText string
+	hostNameOnce sync.Once
+	hostNameText string
+)
+
+func userName() string {
+	userNameOnce.Do(func() {
+		if userNameText = os.Getenv("USERNAME"); userNameText != "" {
+			return
+		}
+		if userNameText = os.Getenv("USER"); userNameText != "" {
+			return
+		}
+		if u, err := user.Current(); err == nil {
+			userNameText = u.Username
+			// DOMAIN\user on Windows
+			if i := strings.LastIndexByte(userNameText, '\\'); i >= 0 {
+				userNameText = userNameText[i+1:]
+			}
+		}
+	})
+	return userNameText
+}
+
+func hostName() string {
+	hostNameOnce.Do(func() {
+		if name, err := os.Hostname(); err == nil {
+			hostNameText = name
+		}
+	})
+	return hostNameText
+}
+
+// shortenPath leaves the last n components of the path with slashes.
+// When n <= 0, it returns path as it is.
+func shortenPath(path string, n int) string {
+	if n <= 0 {
+		return path
+	}
+	pos := len(path)
+	for i := 0; i < n; i++ {
+		j := strings.LastIndexByte(path[:pos], '/')
+		if j < 0 {
+			return path
+		}
+		pos = j
+	}
+	if pos <= 0 || (pos == 2 && path[1] == ':') {
+		// `/foo` or `C:/foo` is not shortened.
+		return path
+	}
+	return path[pos+1:]
+}
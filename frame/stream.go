@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/zetamatta/nyagos/history"
 	"github.com/zetamatta/nyagos/nodos"
@@ -18,6 +19,11 @@ type CmdStreamConsole struct {
 	History  *history.Container
 	Editor   *readline.Editor
 	HistPath string
+	// pending is the command read last which is not saved
+	// because it has not finished yet.
+	pending *history.Line
+	// started is the time when the command read last was started.
+	started time.Time
 }
 
 func NewCmdStreamConsole(doPrompt func() (int, error)) *CmdStreamConsole {
@@ -25,20 +31,49 @@ func NewCmdStreamConsole(doPrompt func() (int, error)) *CmdStreamConsole {
 	this := &CmdStreamConsole{
 		History: history1,
 		Editor: &readline.Editor{
-			History: history1,
-			Prompt:  doPrompt,
-			Writer:  nodos.GetConsole()},
+			History:     history1,
+			Prompt:      doPrompt,
+			Highlighter: Highlight,
+			Suggester:   Suggest,
+			PromptLines: PromptLines,
+			RightPrompt: func() (string, int) {
+				return RightPrompt(os.Getenv("RPROMPT"))
+			},
+			TransientPrompt: func() (string, int) {
+				return TransientPrompt(os.Getenv("TRANSIENT_PROMPT"))
+			},
+			Writer: nodos.GetConsole()},
 		HistPath: filepath.Join(AppDataDir(), "nyagos.history"),
 		CmdSeeker: shell.CmdSeeker{
 			PlainHistory: []string{},
 			Pointer:      -1,
 		},
 	}
-	history1.Load(this.HistPath)
-	history1.Save(this.HistPath)
+	history1.LoadTail(this.HistPath, history.MaxSaveHistory)
+	OnPromptSegmentDone = func() {
+		this.Editor.RefreshPrompt()
+	}
 	return this
 }
 
+// finish saves the command read last with its exit status and duration.
+func (this *CmdStreamConsole) finish() {
+	if this.pending == nil {
+		return
+	}
+	row := this.pending
+	this.pending = nil
+	if err := this.History.Finish(row, shell.LastErrorLevel, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+// Close saves the history which is not saved yet.
+func (this *CmdStreamConsole) Close() error {
+	this.finish()
+	return nil
+}
+
 func (this *CmdStreamConsole) ReadLine(ctx context.Context) (context.Context, string, error) {
 	if this.Pointer >= 0 {
 		if this.Pointer < len(this.PlainHistory) {
@@ -47,15 +82,37 @@ func (this *CmdStreamConsole) ReadLine(ctx context.Context) (context.Context, st
 		}
 		this.Pointer = -1
 	}
+	this.finish()
+	if !this.started.IsZero() {
+		LastDuration = time.Since(this.started)
+	}
+	if history.ShareHistory {
+		if err := this.History.Sync(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}
 	var line string
 	var err error
 	for {
+		ResetHighlightCache()
+		ResetPromptSegments()
 		line, err = this.Editor.ReadLine(ctx)
 		if err != nil {
 			return ctx, line, err
 		}
 		var isReplaced bool
 		line, isReplaced, err = this.History.Replace(line)
+		if err == history.ErrPrintOnly {
+			// `:p` prints the line and saves it without executing.
+			fmt.Fprintln(os.Stdout, line)
+			if text, ok := this.History.Accept(line); ok {
+				row := history.NewHistoryLine(text)
+				this.History.PushLine(row)
+				this.History.Append(&row)
+			}
+			line = ""
+			continue
+		}
 		if err != nil {
 			return ctx, line, err
 		}
@@ -66,15 +123,12 @@ func (this *CmdStreamConsole) ReadLine(ctx context.Context) (context.Context, st
 			break
 		}
 	}
-	row := history.NewHistoryLine(line)
-	this.History.PushLine(row)
-	fd, err := os.OpenFile(this.HistPath, os.O_APPEND|os.O_CREATE, 0600)
-	if err == nil {
-		fmt.Fprintln(fd, row.String())
-		fd.Close()
-	} else {
-		fmt.Fprintln(os.Stderr, err.Error())
+	if text, ok := this.History.Accept(line); ok {
+		row := history.NewHistoryLine(text)
+		this.History.PushLine(row)
+		this.pending = &row
 	}
 	this.PlainHistory = append(this.PlainHistory, line)
+	this.started = time.Now()
 	return ctx, line, err
 }
@@ -50,14 +50,6 @@ func toStr(arr []any_t, n int) string {
 	}
 }
 
-func CmdChdir(args []any_t) []any_t {
-	if len(args) >= 1 {
-		nodos.Chdir(fmt.Sprint(args[0]))
-		return []any_t{true}
-	}
-	return []any_t{nil, "directory is required"}
-}
-
 func CmdBox(this *Param) []any_t {
 	args := this.Args
 	if len(args) < 1 {
@@ -451,3 +443,11 @@ func CmdFields(args []any_t) []any_t {
 	fields := strings.Fields(fmt.Sprint(args[0]))
 	return []any_t{fields}
 }
+
+func CmdHighlight(args []any_t) []any_t {
+	return []any_t{frame.Highlight(toStr(args, 0))}
+}
+
+func CmdSuggest(args []any_t) []any_t {
+	return []any_t{frame.Suggest(toStr(args, 0))}
+}
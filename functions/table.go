@@ -5,7 +5,6 @@ var Table = map[string]func([]interface{}) []interface{}{
 	"atou":           CmdAtoU,
 	"bitand":         CmdBitAnd,
 	"bitor":          CmdBitOr,
-	"chdir":          CmdChdir,
 	"commonprefix":   CmdCommonPrefix,
 	"elevated":       CmdElevated,
 	"fields":         CmdFields,
@@ -15,6 +14,7 @@ var Table = map[string]func([]interface{}) []interface{}{
 	"getviewwidth":   CmdGetViewWidth,
 	"getwd":          CmdGetwd,
 	"glob":           CmdGlob,
+	"highlight":      CmdHighlight,
 	"msgbox":         CmdMsgBox,
 	"netdrivetounc":  CmdNetDriveToUNC,
 	"pathjoin":       CmdPathJoin,
@@ -23,6 +23,7 @@ var Table = map[string]func([]interface{}) []interface{}{
 	"setrunewidth":   CmdSetRuneWidth,
 	"shellexecute":   CmdShellExecute,
 	"stat":           CmdStat,
+	"suggest":        CmdSuggest,
 	"utoa":           CmdUtoA,
 	"which":          CmdWhich,
 }
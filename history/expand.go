@@ -0,0 +1,292 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/zetamatta/nyagos/texts"
+)
+
+// The word designators and the modifiers following the event:
+//
+//	!!:n !!:^ !!:$ !!:* !!:x-y !!:-y !!:x* !!:x-
+//	:h :t :r :e :q :p :s/old/new/ :gs/old/new/ :& :g&
+
+var errBadWordSpecifier = errors.New("bad word specifier")
+
+func readNumber(reader *strings.Reader) (int, bool) {
+	n := 0
+	ok := false
+	for reader.Len() > 0 {
+		ch, _, _ := reader.ReadRune()
+		if !unicode.IsDigit(ch) {
+			reader.UnreadRune()
+			break
+		}
+		n = n*10 + int(ch-'0')
+		ok = true
+	}
+	return n, ok
+}
+
+// readWordIndex reads `n`, `^` or `$` and returns the index of the word.
+func readWordIndex(reader *strings.Reader, count int) (int, bool) {
+	if reader.Len() <= 0 {
+		return 0, false
+	}
+	ch, _, _ := reader.ReadRune()
+	switch {
+	case ch == '^':
+		return 1, true
+	case ch == '$':
+		return count - 1, true
+	case unicode.IsDigit(ch):
+		reader.UnreadRune()
+		return readNumber(reader)
+	}
+	reader.UnreadRune()
+	return 0, false
+}
+
+// selectWords reads the word designator and returns the words selected
+// from line. When there is no designator, it returns the whole line.
+func selectWords(reader *strings.Reader, line string) (string, error) {
+	if reader.Len() <= 0 {
+		return line, nil
+	}
+	ch, _, _ := reader.ReadRune()
+	if ch == ':' {
+		if reader.Len() <= 0 {
+			reader.UnreadRune()
+			return line, nil
+		}
+		ch, _, _ = reader.ReadRune()
+		if !unicode.IsDigit(ch) && strings.IndexRune("^$*-", ch) < 0 {
+			// not a word designator but a modifier
+			reader.Seek(-int64(1+len(string(ch))), io.SeekCurrent)
+			return line, nil
+		}
+	} else if strings.IndexRune("^$*", ch) < 0 {
+		reader.UnreadRune()
+		return line, nil
+	}
+	words := texts.SplitLikeShellString(line)
+	if ch == '*' {
+		if len(words) < 2 {
+			return "", nil
+		}
+		return strings.Join(words[1:], " "), nil
+	}
+	first := 0
+	if ch != '-' {
+		reader.UnreadRune()
+		var ok bool
+		first, ok = readWordIndex(reader, len(words))
+		if !ok {
+			return "", errBadWordSpecifier
+		}
+		if reader.Len() <= 0 {
+			return oneWord(words, first)
+		}
+		ch, _, _ = reader.ReadRune()
+		if ch == '*' { // x* is same as x-$
+			if first >= len(words) {
+				return "", errBadWordSpecifier
+			}
+			return strings.Join(words[first:], " "), nil
+		}
+		if ch != '-' {
+			reader.UnreadRune()
+			return oneWord(words, first)
+		}
+	}
+	// x-y , -y or x- (x to $-1)
+	last, ok := readWordIndex(reader, len(words))
+	if !ok {
+		last = len(words) - 2
+	}
+	if first > last || last >= len(words) {
+		return "", errBadWordSpecifier
+	}
+	return strings.Join(words[first:last+1], " "), nil
+}
+
+func oneWord(words []string, n int) (string, error) {
+	if n < 0 || n >= len(words) {
+		return "", errBadWordSpecifier
+	}
+	return words[n], nil
+}
+
+// unquoted calls f with the text removed the double-quotations around,
+// and encloses the result with them again.
+func unquoted(text string, f func(string) string) string {
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		return `"` + f(text[1:len(text)-1]) + `"`
+	}
+	return f(text)
+}
+
+func lastSeparator(path string) int {
+	return strings.LastIndexAny(path, `\/`)
+}
+
+// modifierHead removes the last element of the path (`:h`)
+func modifierHead(path string) string {
+	if i := lastSeparator(path); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// modifierTail removes the elements of the path but the last one (`:t`)
+func modifierTail(path string) string {
+	return path[lastSeparator(path)+1:]
+}
+
+// modifierRoot removes the suffix `.xxx` of the path (`:r`)
+func modifierRoot(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i > lastSeparator(path) {
+		return path[:i]
+	}
+	return path
+}
+
+// modifierExt removes the path but the suffix `.xxx` (`:e`)
+func modifierExt(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i > lastSeparator(path) {
+		return path[i:]
+	}
+	return ""
+}
+
+// readDelimited reads the text until the delimiter or the end of line.
+// The delimiter escaped with a backslash is read as itself.
+func readDelimited(reader *strings.Reader, delim rune) string {
+	var buffer strings.Builder
+	for reader.Len() > 0 {
+		ch, _, _ := reader.ReadRune()
+		if ch == delim {
+			break
+		}
+		if ch == '\\' && reader.Len() > 0 {
+			next, _, _ := reader.ReadRune()
+			if next != delim {
+				buffer.WriteRune(ch)
+			}
+			ch = next
+		}
+		buffer.WriteRune(ch)
+	}
+	return buffer.String()
+}
+
+// substitute replaces old in text with repl, where `&` in repl means old.
+func substitute(text, old, repl string, global bool) (string, bool) {
+	if old == "" || !strings.Contains(text, old) {
+		return text, false
+	}
+	var replace strings.Builder
+	for i := 0; i < len(repl); i++ {
+		if repl[i] == '\\' && i+1 < len(repl) && repl[i+1] == '&' {
+			replace.WriteByte('&')
+			i++
+		} else if repl[i] == '&' {
+			replace.WriteString(old)
+		} else {
+			replace.WriteByte(repl[i])
+		}
+	}
+	n := 1
+	if global {
+		n = -1
+	}
+	return strings.Replace(text, old, replace.String(), n), true
+}
+
+// modify reads the modifiers and applies them to text.
+// It returns true as the second value when `:p` is given.
+func (hisObj *Container) modify(reader *strings.Reader, text string) (string, bool, error) {
+	printOnly := false
+	for reader.Len() > 0 {
+		ch, _, _ := reader.ReadRune()
+		if ch != ':' || reader.Len() <= 0 {
+			reader.UnreadRune()
+			break
+		}
+		m, _, _ := reader.ReadRune()
+		if !unicode.IsLetter(m) && m != '&' {
+			// `:` not followed by a modifier is left as it is.
+			reader.Seek(-int64(1+len(string(m))), io.SeekCurrent)
+			break
+		}
+		global := false
+		if m == 'g' && reader.Len() > 0 {
+			global = true
+			m, _, _ = reader.ReadRune()
+		}
+		switch m {
+		case 'h':
+			text = unquoted(text, modifierHead)
+		case 't':
+			text = unquoted(text, modifierTail)
+		case 'r':
+			text = unquoted(text, modifierRoot)
+		case 'e':
+			text = unquoted(text, modifierExt)
+		case 'q':
+			text = `"` + strings.Replace(text, `"`, ``, -1) + `"`
+		case 'p':
+			printOnly = true
+		case 's':
+			if reader.Len() <= 0 {
+				return "", false, errors.New(":s: no delimiter")
+			}
+			delim, _, _ := reader.ReadRune()
+			old := readDelimited(reader, delim)
+			repl := readDelimited(reader, delim)
+			if old == "" {
+				old = hisObj.substOld
+			}
+			hisObj.substOld = old
+			hisObj.substNew = repl
+			var ok bool
+			text, ok = substitute(text, old, repl, global)
+			if !ok {
+				return "", false, fmt.Errorf(":s%c%s%c%s%c: substitution failed",
+					delim, old, delim, repl, delim)
+			}
+		case '&':
+			if hisObj.substOld == "" {
+				return "", false, errors.New(":&: no previous substitution")
+			}
+			var ok bool
+			text, ok = substitute(text, hisObj.substOld, hisObj.substNew, global)
+			if !ok {
+				return "", false, errors.New(":&: substitution failed")
+			}
+		default:
+			return "", false, fmt.Errorf(":%c: unrecognized history modifier", m)
+		}
+	}
+	return text, printOnly, nil
+}
+
+// expandMacro writes line (the event of history) selected by the word
+// designator and changed by the modifiers read from reader.
+// It returns true when `:p` is given.
+func (hisObj *Container) expandMacro(buffer *strings.Builder, reader *strings.Reader, line string) (bool, error) {
+	text, err := selectWords(reader, line)
+	if err != nil {
+		return false, err
+	}
+	text, printOnly, err := hisObj.modify(reader, text)
+	if err != nil {
+		return false, err
+	}
+	buffer.WriteString(text)
+	return printOnly, nil
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,13 +16,17 @@ import (
 	"unicode"
 
 	"github.com/mattn/go-isatty"
-	"github.com/zetamatta/nyagos/texts"
 )
 
 var Mark = "!"
 
 var DisableMarks = "\"'"
 
+// ErrPrintOnly is returned by Replace with the line expanded when
+// the modifier `:p` is given. The line should be printed and saved
+// into the history, but not executed.
+var ErrPrintOnly = errors.New("history: print only")
+
 func (hisObj *Container) Replace(line string) (string, bool, error) {
 	var mark rune
 	for _, c := range Mark {
@@ -29,13 +34,30 @@ func (hisObj *Container) Replace(line string) (string, bool, error) {
 		break
 	}
 
+	// ^old^new^ is same as !!:s^old^new^
+	// It is disabled with the history expansion when Mark is empty.
+	if Mark != "" && strings.HasPrefix(line, "^") && strings.Count(line, "^") >= 2 {
+		line = string(mark) + string(mark) + ":s" + line
+	}
+
 	var buffer strings.Builder
 	isReplaced := false
+	printOnly := false
 	reader := strings.NewReader(line)
 	history_count := hisObj.Len()
 
 	quotedChar := '\000'
 
+	expand := func(event string) error {
+		p, err := hisObj.expandMacro(&buffer, reader, event)
+		if err != nil {
+			return err
+		}
+		isReplaced = true
+		printOnly = printOnly || p
+		return nil
+	}
+
 	for reader.Len() > 0 {
 		ch, _, _ := reader.ReadRune()
 		if quotedChar == '\000' && strings.IndexRune(DisableMarks, ch) >= 0 {
@@ -60,17 +82,17 @@ func (hisObj *Container) Replace(line string) (string, bool, error) {
 		if n := strings.IndexRune("^$:*", ch); n >= 0 {
 			reader.UnreadRune()
 			if history_count >= 1 {
-				line := hisObj.At(history_count - 1)
-				expandMacro(&buffer, reader, line)
-				isReplaced = true
+				if err := expand(hisObj.At(history_count - 1)); err != nil {
+					return "", false, err
+				}
 			}
 			continue
 		}
 		if ch == mark { // !!
 			if history_count >= 1 {
-				line := hisObj.At(history_count - 1)
-				expandMacro(&buffer, reader, line)
-				isReplaced = true
+				if err := expand(hisObj.At(history_count - 1)); err != nil {
+					return "", false, err
+				}
 				continue
 			} else {
 				return "", false, errors.New("!!: event not found")
@@ -81,9 +103,9 @@ func (hisObj *Container) Replace(line string) (string, bool, error) {
 			var backno int
 			fmt.Fscan(reader, &backno)
 			if 0 <= backno && backno < history_count {
-				line := hisObj.At(backno)
-				expandMacro(&buffer, reader, line)
-				isReplaced = true
+				if err := expand(hisObj.At(backno)); err != nil {
+					return "", false, err
+				}
 			} else {
 				return "", false, fmt.Errorf("!%d: event not found", backno)
 			}
@@ -94,9 +116,9 @@ func (hisObj *Container) Replace(line string) (string, bool, error) {
 			if _, err := fmt.Fscan(reader, &number); err == nil {
 				backno := history_count - number
 				if 0 <= backno && backno < history_count {
-					line := hisObj.At(backno)
-					expandMacro(&buffer, reader, line)
-					isReplaced = true
+					if err := expand(hisObj.At(backno)); err != nil {
+						return "", false, err
+					}
 				} else {
 					return "", false, fmt.Errorf("!-%d: event not found", number)
 				}
@@ -121,8 +143,9 @@ func (hisObj *Container) Replace(line string) (string, bool, error) {
 			for i := history_count - 1; i >= 0; i-- {
 				his1 := hisObj.At(i)
 				if strings.Contains(his1, seekStr) {
-					expandMacro(&buffer, reader, his1)
-					isReplaced = true
+					if err := expand(his1); err != nil {
+						return "", false, err
+					}
 					found = true
 					break
 				}
@@ -152,8 +175,9 @@ func (hisObj *Container) Replace(line string) (string, bool, error) {
 		for i := history_count - 1; i >= 0; i-- {
 			his1 := hisObj.At(i)
 			if strings.HasPrefix(his1, seekStr) {
-				expandMacro(&buffer, reader, his1)
-				isReplaced = true
+				if err := expand(his1); err != nil {
+					return "", false, err
+				}
 				found = true
 				break
 			}
@@ -162,47 +186,81 @@ func (hisObj *Container) Replace(line string) (string, bool, error) {
 			return "", false, fmt.Errorf("%c%s: event not found", mark, seekStr)
 		}
 	}
+	if printOnly {
+		return buffer.String(), isReplaced, ErrPrintOnly
+	}
 	return buffer.String(), isReplaced, nil
 }
 
-func expandMacro(buffer *strings.Builder, reader *strings.Reader, line string) {
-	ch, siz, _ := reader.ReadRune()
-	if siz > 0 && ch == ':' {
-		ch, siz, _ = reader.ReadRune()
+type Param interface {
+	Arg(int) string
+	Args() []string
+	Out() io.Writer
+	Err() io.Writer
+}
+
+// parseSince reads the time for `history --since`: `2006-01-02`,
+// `2006-01-02 15:04:05` or the duration before now as `2h30m`
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
 	}
-	if siz > 0 && ch == '^' {
-		if words := texts.SplitLikeShellString(line); len(words) >= 2 {
-			buffer.WriteString(words[1])
-		}
-	} else if siz > 0 && ch == '$' {
-		if words := texts.SplitLikeShellString(line); len(words) >= 2 {
-			buffer.WriteString(words[len(words)-1])
+	if t, err := time.ParseInLocation(stampFormat, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+func printRow(w io.Writer, i int, row *Line, home string) {
+	dir := row.Dir
+	if home != "" && strings.HasPrefix(strings.ToUpper(dir), strings.ToUpper(home)) {
+		dir = "~" + dir[len(home):]
+	}
+	dir = filepath.ToSlash(dir)
+	fmt.Fprintf(w, "%4d  %s [%d] %-s (%s)",
+		i,
+		row.Stamp.Format("Jan _2 15:04:05"),
+		row.Pid,
+		row.Text,
+		dir)
+	if row.ExitCode != 0 {
+		fmt.Fprintf(w, " => %d", row.ExitCode)
+	}
+	fmt.Fprintln(w)
+}
+
+// purge removes the N-th record (`-d N`) or the records matching
+// the regular expression (`--purge PATTERN`) from memory and disk.
+func purge(historyObj *Container, option, value string, out io.Writer) (int, error) {
+	var match func(*Line) bool
+	if option == "-d" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 1, fmt.Errorf("history -d %s: not a number", value)
 		}
-	} else if siz > 0 && ch == '*' {
-		if words := texts.SplitLikeShellString(line); len(words) >= 2 {
-			buffer.WriteString(strings.Join(words[1:], " "))
+		if n < 0 {
+			n += historyObj.Len()
 		}
-	} else if siz > 0 && unicode.IsDigit(ch) {
-		var n int
-		reader.UnreadRune()
-		fmt.Fscan(reader, &n)
-		words := texts.SplitLikeShellString(line)
-		if n < len(words) {
-			buffer.WriteString(words[n])
+		if n < 0 || n >= historyObj.Len() {
+			return 1, fmt.Errorf("history -d %s: out of range", value)
 		}
+		key := historyObj.rows[n].key()
+		match = func(row *Line) bool { return row.key() == key }
 	} else {
-		if siz > 0 {
-			reader.UnreadRune()
+		rx, err := regexp.Compile(value)
+		if err != nil {
+			return 1, fmt.Errorf("history --purge %s: %s", value, err)
 		}
-		buffer.WriteString(line)
+		match = func(row *Line) bool { return rx.MatchString(row.Text) }
 	}
-}
-
-type Param interface {
-	Arg(int) string
-	Args() []string
-	Out() io.Writer
-	Err() io.Writer
+	count, err := historyObj.Remove(match)
+	if err != nil {
+		return 1, err
+	}
+	if option != "-d" {
+		fmt.Fprintf(out, "%d entries removed\n", count)
+	}
+	return 0, nil
 }
 
 func CmdHistory(ctx context.Context, cmd Param) (int, error) {
@@ -210,14 +268,71 @@ func CmdHistory(ctx context.Context, cmd Param) (int, error) {
 		fmt.Fprintln(cmd.Err(), "history not found (case1)")
 		return 1, nil
 	}
-	var num int
-	if len(cmd.Args()) >= 2 {
-		num64, err := strconv.ParseInt(cmd.Arg(1), 0, 32)
+	historyObj, ok := ctx.Value(PackageId).(*Container)
+	if !ok {
+		return -1, errors.New("history: not available in startup script")
+	}
+	num := -1
+	var filter *Filter
+	args := cmd.Args()
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-d" || arg == "--purge" {
+			if i+1 >= len(args) {
+				return 1, fmt.Errorf("history: %s: requires a parameter", arg)
+			}
+			return purge(historyObj, arg, args[i+1], cmd.Out())
+		}
+		if arg == "--failed" {
+			if filter == nil {
+				filter = &Filter{}
+			}
+			filter.Failed = true
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			if i+1 >= len(args) {
+				return 1, fmt.Errorf("history: %s: requires a parameter", arg)
+			}
+			i++
+			value := args[i]
+			if filter == nil {
+				filter = &Filter{}
+			}
+			switch arg {
+			case "--dir":
+				if value == "." {
+					value, _ = os.Getwd()
+				}
+				filter.Dir = value
+			case "--since":
+				since, err := parseSince(value)
+				if err != nil {
+					return 1, fmt.Errorf("history: --since %s: %s", value, err)
+				}
+				filter.Since = since
+			case "--session":
+				if value == "current" {
+					value = SessionID
+				}
+				filter.Session = value
+			case "--grep":
+				rx, err := regexp.Compile(value)
+				if err != nil {
+					return 1, fmt.Errorf("history: --grep %s: %s", value, err)
+				}
+				filter.Grep = rx
+			default:
+				return 1, fmt.Errorf("history: %s: unknown option", arg)
+			}
+			continue
+		}
+		num64, err := strconv.ParseInt(arg, 0, 32)
 		if err != nil {
 			switch err.(type) {
 			case *strconv.NumError:
 				return 0, fmt.Errorf(
-					"history: %s not a number", cmd.Arg(1))
+					"history: %s not a number", arg)
 			default:
 				return 0, err
 			}
@@ -226,32 +341,40 @@ func CmdHistory(ctx context.Context, cmd Param) (int, error) {
 		if num < 0 {
 			num = -num
 		}
-	} else {
-		num = 10
 	}
-	start := 0
+	home := os.Getenv("USERPROFILE")
 
-	historyObj, ok := ctx.Value(PackageId).(*Container)
-	if !ok {
-		return -1, errors.New("history: not available in startup script")
+	if filter != nil {
+		// The filters are applied to all the records on the disk.
+		if historyObj.path == "" {
+			return 1, errors.New("history: the history file is not available")
+		}
+		rows := []*Line{}
+		err := Query(historyObj.path, filter, func(row *Line) bool {
+			rows = append(rows, row)
+			if num >= 0 && len(rows) > num {
+				rows = rows[1:]
+			}
+			return true
+		})
+		if err != nil {
+			return 1, err
+		}
+		for i, row := range rows {
+			printRow(cmd.Out(), i, row, home)
+		}
+		return 0, nil
+	}
+
+	if num < 0 {
+		num = 10
 	}
+	start := 0
 	if f, ok := cmd.Out().(*os.File); ok && isatty.IsTerminal(f.Fd()) && historyObj.Len() > num {
 		start = historyObj.Len() - num
 	}
-	home := os.Getenv("USERPROFILE")
 	for i := start; i < historyObj.Len(); i++ {
-		row := historyObj.rows[i]
-		dir := row.Dir
-		if strings.HasPrefix(strings.ToUpper(dir), strings.ToUpper(home)) {
-			dir = "~" + dir[len(home):]
-		}
-		dir = filepath.ToSlash(dir)
-		fmt.Fprintf(cmd.Out(), "%4d  %s [%d] %-s (%s)\n",
-			i,
-			row.Stamp.Format("Jan _2 15:04:05"),
-			row.Pid,
-			row.Text,
-			dir)
+		printRow(cmd.Out(), i, &historyObj.rows[i], home)
 	}
 	return 0, nil
 }
@@ -282,26 +405,9 @@ func (hisObj *Container) Save(path string) error {
 func (hisObj *Container) LoadViaReader(reader io.Reader) {
 	sc := bufio.NewScanner(reader)
 	for sc.Scan() {
-		line := sc.Text()
-		p := strings.Split(line, "\t")
-
-		var stamp time.Time
-		var dir string
-		pid := 0
-		if len(p) >= 3 {
-			dir = p[1]
-			stamp, _ = time.Parse("2006-01-02 15:04:05", p[2])
-			if len(p) >= 4 {
-				pid, _ = strconv.Atoi(p[3])
-			}
-		}
-		hisObj.PushLine(Line{
-			Text:  p[0],
-			Dir:   dir,
-			Stamp: stamp,
-			Pid:   pid})
+		hisObj.PushLine(parseLine(sc.Text()))
 	}
-	sort.Slice(hisObj.rows, func(i, j int) bool {
+	sort.SliceStable(hisObj.rows, func(i, j int) bool {
 		p := hisObj.rows[i]
 		q := hisObj.rows[j]
 		if p.Stamp != q.Stamp {
@@ -309,6 +415,31 @@ func (hisObj *Container) LoadViaReader(reader io.Reader) {
 		}
 		return p.Text < q.Text
 	})
+	if IgnoreDups || EraseDups {
+		hisObj.rows = removeDups(hisObj.rows, EraseDups)
+	}
+}
+
+// removeDups removes the lines same as the previous ones.
+// When all is true, the older lines same as the newer ones are removed too.
+func removeDups(rows []Line, all bool) []Line {
+	result := make([]Line, 0, len(rows))
+	newest := map[string]int{}
+	if all {
+		for i, row := range rows {
+			newest[row.Text] = i
+		}
+	}
+	for i, row := range rows {
+		if all && newest[row.Text] != i {
+			continue
+		}
+		if len(result) > 0 && result[len(result)-1].Text == row.Text {
+			continue
+		}
+		result = append(result, row)
+	}
+	return result
 }
 
 func (hisObj *Container) Load(path string) error {
@@ -49,28 +49,128 @@ func TestReplace(t *testing.T) {
 
 func TestExpandMacro(t *testing.T) {
 	var buffer strings.Builder
+	hisObj := &Container{}
 
-	expandMacro(&buffer, strings.NewReader("^"), "aaa bbb ccc")
+	hisObj.expandMacro(&buffer, strings.NewReader("^"), "aaa bbb ccc")
 	if buffer.String() != "bbb" {
 		t.Fail()
 		return
 	}
 
 	buffer.Reset()
-	expandMacro(&buffer, strings.NewReader("$"), "aaa bbb ccc ddd")
+	hisObj.expandMacro(&buffer, strings.NewReader("$"), "aaa bbb ccc ddd")
 	if buffer.String() != "ddd" {
 		t.Fail()
 		return
 	}
 
 	buffer.Reset()
-	expandMacro(&buffer, strings.NewReader(":1"), `aaa "b bb" ccc ddd`)
+	hisObj.expandMacro(&buffer, strings.NewReader(":1"), `aaa "b bb" ccc ddd`)
 	if buffer.String() != `"b bb"` {
 		t.Fail()
 		return
 	}
 }
 
+func TestExpandWords(t *testing.T) {
+	line := `cp "C:\My Documents\a.txt" b.c d/e.tar.gz`
+	testcases := []struct {
+		source string
+		expect string
+	}{
+		{"", line},
+		{":0", "cp"},
+		{":1-2", `"C:\My Documents\a.txt" b.c`},
+		{":-2", `cp "C:\My Documents\a.txt" b.c`},
+		{":2*", "b.c d/e.tar.gz"},
+		{":1-", `"C:\My Documents\a.txt" b.c`},
+		{":^-$", `"C:\My Documents\a.txt" b.c d/e.tar.gz`},
+		{"*", `"C:\My Documents\a.txt" b.c d/e.tar.gz`},
+		{":1:h", `"C:\My Documents"`},
+		{":1:t", `"a.txt"`},
+		{"^:t:r", `"a"`},
+		{":2:r", "b"},
+		{":2:e", ".c"},
+		{"$:h", "d"},
+		{"$:t:r", "e.tar"},
+		{"$:r:r", "d/e"},
+		{":2:q", `"b.c"`},
+		{":s/c/x/", `xp "C:\My Documents\a.txt" b.c d/e.tar.gz`},
+		{":gs/./_/", `cp "C:\My Documents\a_txt" b_c d/e_tar_gz`},
+		{":2:s/b/&&/", "bb.c"},
+		{`:2:s/b/\&/`, "&.c"},
+		{":$:s|/|\\||", "d|e.tar.gz"},
+		{":0:s/cp/copy", "copy"},
+		{":1-2 rest", `"C:\My Documents\a.txt" b.c rest`},
+		{":$: rest", "d/e.tar.gz: rest"},
+	}
+	for _, tc := range testcases {
+		var buffer strings.Builder
+		hisObj := &Container{}
+		reader := strings.NewReader(tc.source)
+		if _, err := hisObj.expandMacro(&buffer, reader, line); err != nil {
+			t.Fatalf("%s: %s", tc.source, err.Error())
+		}
+		var rest strings.Builder
+		reader.WriteTo(&rest)
+		if result := buffer.String() + rest.String(); result != tc.expect {
+			t.Fatalf("%s: `%s` != `%s`", tc.source, result, tc.expect)
+		}
+	}
+	for _, source := range []string{":9", ":3-1", ":1:z", ":s/zzz/y/", ":&"} {
+		var buffer strings.Builder
+		hisObj := &Container{}
+		if _, err := hisObj.expandMacro(&buffer, strings.NewReader(source), line); err == nil {
+			t.Fatalf("%s: should fail but `%s`", source, buffer.String())
+		}
+	}
+}
+
+func TestReplaceModifiers(t *testing.T) {
+	hisObj := &Container{}
+	hisObj.Push("make test")
+	hisObj.Push("vim src/main.go")
+
+	testcases := []struct {
+		source string
+		expect string
+	}{
+		{"!!", "vim src/main.go"},
+		{"ls !$:h", "ls src"},
+		{"!-2:s/test/build/", "make build"},
+		{"!m:0 install", "make install"},
+		{"!?main?:$:t:r", "main"},
+		{"^vim^gvim^", "gvim src/main.go"},
+		{"^main^util", "vim src/util.go"},
+		{"!v:gs/m/M/", "viM src/Main.go"},
+		{"!!:&", "viM src/main.go"},
+		{"echo '!!'", "echo '!!'"},
+	}
+	for _, tc := range testcases {
+		result, _, err := hisObj.Replace(tc.source)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.source, err.Error())
+		}
+		if result != tc.expect {
+			t.Fatalf("%s: `%s` != `%s`", tc.source, result, tc.expect)
+		}
+	}
+
+	result, isReplaced, err := hisObj.Replace("!!:s/vim/emacs/:p")
+	if err != ErrPrintOnly || !isReplaced || result != "emacs src/main.go" {
+		t.Fatalf("!!:p: `%s`,%v,%v", result, isReplaced, err)
+	}
+	if _, _, err := hisObj.Replace("!!:s/xxx/yyy/"); err == nil {
+		t.Fatal("!!:s/xxx/yyy/ should fail")
+	}
+
+	defer func(mark string) { Mark = mark }(Mark)
+	Mark = ""
+	if result, isReplaced, err := hisObj.Replace("^vim^gvim^"); err != nil || isReplaced || result != "^vim^gvim^" {
+		t.Fatalf("^vim^gvim^ without Mark: `%s`,%v,%v", result, isReplaced, err)
+	}
+}
+
 func TestLoadFromReader(t *testing.T) {
 	source := `aaaa
 aaaa
@@ -103,3 +203,26 @@ cccc`
 // 		t.Fail()
 // 	}
 // }
+
+func TestSuggest(t *testing.T) {
+	var c Container
+	c.PushLine(Line{Text: "git status", Dir: `C:\foo`})
+	c.PushLine(Line{Text: "git stash", Dir: `C:\bar`})
+	c.PushLine(Line{Text: "git", Dir: `C:\bar`})
+
+	if s := c.Suggest("git st", `C:\bar`); s != "git stash" {
+		t.Fatalf("Suggest(`git st`,`C:\\bar`) = %q", s)
+	}
+	if s := c.Suggest("git st", `c:\foo`); s != "git status" {
+		t.Fatalf("Suggest(`git st`,`c:\\foo`) = %q", s)
+	}
+	if s := c.Suggest("git st", `C:\baz`); s != "git stash" {
+		t.Fatalf("Suggest(`git st`,`C:\\baz`) = %q", s)
+	}
+	if s := c.Suggest("git", ""); s != "git stash" {
+		t.Fatalf("Suggest(`git`,``) = %q", s)
+	}
+	if s := c.Suggest("svn", ""); s != "" {
+		t.Fatalf("Suggest(`svn`,``) = %q", s)
+	}
+}
@@ -0,0 +1,216 @@
+package history
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/zetamatta/nyagos/nodos"
+)
+
+var (
+	// IgnoreDups is true not to save the line same as the previous one.
+	IgnoreDups = false
+	// EraseDups is true to remove the older lines same as the new one.
+	EraseDups = false
+	// IgnoreSpace is true not to save the line starting with spaces.
+	IgnoreSpace = false
+)
+
+// IgnorePatterns are the regular expressions. The lines matching
+// them are never saved.
+var IgnorePatterns = []string{}
+
+// MaskPatterns are the regular expressions. The lines matching them
+// are saved with the secret masked. When the pattern has a group,
+// only the text of the first group is masked.
+var MaskPatterns = []string{}
+
+const secretMask = "********"
+
+var (
+	compiledPatterns = map[string]*regexp.Regexp{}
+	compiledMutex    sync.Mutex
+)
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	compiledMutex.Lock()
+	defer compiledMutex.Unlock()
+	if rx, ok := compiledPatterns[pattern]; ok {
+		return rx, nil
+	}
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns[pattern] = rx
+	return rx, nil
+}
+
+// CheckPatterns returns the error of the first pattern which can not be
+// compiled. IgnorePatterns and MaskPatterns should be checked with it
+// before they are set.
+func CheckPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := compilePattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maskSecret replaces the matched text (or its first group) with the mask.
+func maskSecret(rx *regexp.Regexp, text string) string {
+	var buffer strings.Builder
+	last := 0
+	for _, m := range rx.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		buffer.WriteString(text[last:start])
+		buffer.WriteString(secretMask)
+		last = end
+	}
+	buffer.WriteString(text[last:])
+	return buffer.String()
+}
+
+// Scrub returns the text to be saved in the history. When the text
+// should not be saved, it returns false.
+func Scrub(text string) (string, bool) {
+	if IgnoreSpace && text != "" && (text[0] == ' ' || text[0] == '\t') {
+		return "", false
+	}
+	for _, pattern := range IgnorePatterns {
+		if rx, err := compilePattern(pattern); err == nil && rx.MatchString(text) {
+			return "", false
+		}
+	}
+	for _, pattern := range MaskPatterns {
+		if rx, err := compilePattern(pattern); err == nil {
+			text = maskSecret(rx, text)
+		}
+	}
+	return text, true
+}
+
+// Accept returns the text to push into the history with IgnoreDups,
+// EraseDups, IgnoreSpace, IgnorePatterns and MaskPatterns.
+// When EraseDups is set, the older lines same as text are removed from
+// the memory. When the text should not be saved, it returns false.
+func (hisObj *Container) Accept(text string) (string, bool) {
+	text, ok := Scrub(text)
+	if !ok {
+		return "", false
+	}
+	if IgnoreDups && len(hisObj.rows) > 0 && hisObj.rows[len(hisObj.rows)-1].Text == text {
+		return "", false
+	}
+	if EraseDups {
+		rows := hisObj.rows[:0]
+		for _, row := range hisObj.rows {
+			if row.Text != text {
+				rows = append(rows, row)
+			}
+		}
+		hisObj.rows = rows
+	}
+	return text, true
+}
+
+// Remove deletes the records for which match returns true from both of
+// the memory and the history file. It returns the number of the records
+// removed from the memory.
+func (hisObj *Container) Remove(match func(*Line) bool) (int, error) {
+	// The records appended by other sessions are read before the offset
+	// is moved to the end of the rewritten file.
+	if err := hisObj.Sync(); err != nil {
+		return 0, err
+	}
+	rows := hisObj.rows[:0]
+	count := 0
+	for i := range hisObj.rows {
+		if match(&hisObj.rows[i]) {
+			count++
+		} else {
+			rows = append(rows, hisObj.rows[i])
+		}
+	}
+	hisObj.rows = rows
+	if hisObj.path == "" {
+		return count, nil
+	}
+	size, err := rewriteFile(hisObj.path, match)
+	if err != nil {
+		return count, err
+	}
+	hisObj.offset = size
+	return count, nil
+}
+
+// rewriteFile removes the records matching from the history file
+// locking it. The rest are written into the temporary file which
+// replaces the history file not to lose them when it fails on the way.
+// It returns the new size of the file.
+func rewriteFile(path string, match func(*Line) bool) (int64, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			nodos.UnlockFile(fd)
+			fd.Close()
+		}
+	}()
+	if err := nodos.LockFile(fd); err != nil {
+		fd.Close()
+		closed = true
+		return 0, err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	w := bufio.NewWriter(tmp)
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		row := parseLine(sc.Text())
+		if !match(&row) {
+			n, _ := w.WriteString(sc.Text() + "\n")
+			size += int64(n)
+		}
+	}
+	err = sc.Err()
+	if err == nil {
+		err = w.Flush()
+	}
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		if err = os.Rename(tmp.Name(), path); err != nil {
+			// Windows can not replace the file opened.
+			nodos.UnlockFile(fd)
+			fd.Close()
+			closed = true
+			err = os.Rename(tmp.Name(), path)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return size, nil
+}
@@ -0,0 +1,136 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrub(t *testing.T) {
+	defer func(ignore, mask []string, space bool) {
+		IgnorePatterns, MaskPatterns, IgnoreSpace = ignore, mask, space
+	}(IgnorePatterns, MaskPatterns, IgnoreSpace)
+
+	IgnorePatterns = []string{`AWS_SECRET`}
+	MaskPatterns = []string{`--password=(\S+)`, `token [0-9a-f]+`}
+	IgnoreSpace = true
+
+	tests := []struct {
+		source string
+		expect string
+		ok     bool
+	}{
+		{`ls -l`, `ls -l`, true},
+		{` ls -l`, ``, false},
+		{`set AWS_SECRET=xxx`, ``, false},
+		{`login --password=hoge --user=foo`, `login --password=` + secretMask + ` --user=foo`, true},
+		{`curl token 0123abc`, `curl ` + secretMask, true},
+	}
+	for _, test := range tests {
+		result, ok := Scrub(test.source)
+		if result != test.expect || ok != test.ok {
+			t.Fatalf("Scrub(%q) = (%q,%v), expected (%q,%v)",
+				test.source, result, ok, test.expect, test.ok)
+		}
+	}
+}
+
+func TestCheckPatterns(t *testing.T) {
+	if err := CheckPatterns([]string{`AWS_SECRET`, `--password=(\S+)`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPatterns([]string{`ok`, `(unclosed`}); err == nil {
+		t.Fatal("CheckPatterns should fail with `(unclosed`")
+	}
+}
+
+func TestAcceptDups(t *testing.T) {
+	defer func(ignore, erase bool) {
+		IgnoreDups, EraseDups = ignore, erase
+	}(IgnoreDups, EraseDups)
+
+	c := &Container{}
+	c.Push("aaa")
+	c.Push("bbb")
+	IgnoreDups = true
+	if _, ok := c.Accept("bbb"); ok {
+		t.Fatal("Accept should ignore the same line as the previous one")
+	}
+	EraseDups = true
+	if text, ok := c.Accept("aaa"); !ok || text != "aaa" {
+		t.Fatal("Accept should accept `aaa`")
+	}
+	if c.Len() != 1 || c.At(0) != "bbb" {
+		t.Fatalf("EraseDups did not remove the older `aaa`: Len()=%d", c.Len())
+	}
+}
+
+func TestLoadDups(t *testing.T) {
+	defer func(ignore, erase bool) {
+		IgnoreDups, EraseDups = ignore, erase
+	}(IgnoreDups, EraseDups)
+
+	data := "aaa\t\t2019-01-01 00:00:00\t1\n" +
+		"aaa\t\t2019-01-01 00:00:01\t1\n" +
+		"bbb\t\t2019-01-01 00:00:02\t1\n" +
+		"aaa\t\t2019-01-01 00:00:03\t1\n"
+	tests := []struct {
+		ignore, erase bool
+		expect        int
+	}{
+		{false, false, 4},
+		{true, false, 3},
+		{false, true, 2},
+	}
+	for _, p := range tests {
+		IgnoreDups, EraseDups = p.ignore, p.erase
+		c := &Container{}
+		c.LoadViaReader(strings.NewReader(data))
+		if c.Len() != p.expect {
+			t.Errorf("IgnoreDups=%v EraseDups=%v: Len()=%d (expected %d)",
+				p.ignore, p.erase, c.Len(), p.expect)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "nyagos-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "nyagos.history")
+
+	c := &Container{}
+	c.LoadTail(path, MaxSaveHistory)
+	for _, text := range []string{"ls", "login --password=hoge", "pwd"} {
+		row := Line{Text: text, Stamp: time.Now()}
+		c.PushLine(row)
+		c.Append(&row)
+	}
+	// appended by the other session
+	other := &Container{}
+	other.LoadTail(path, MaxSaveHistory)
+	row := Line{Text: "echo other", Stamp: time.Now(), Session: "other"}
+	other.Append(&row)
+
+	count, err := c.Remove(func(row *Line) bool {
+		return strings.Contains(row.Text, "password")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || c.Len() != 3 || c.At(2) != "echo other" {
+		t.Fatalf("Remove: count=%d Len()=%d", count, c.Len())
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "password") || strings.Count(string(data), "\n") != 3 {
+		t.Fatalf("the history file is not rewritten:\n%s", data)
+	}
+}
@@ -0,0 +1,132 @@
+package history
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	writerCount = 4
+	writerLines = 200
+)
+
+// TestWriterProcess is the child process of TestConcurrentWriters.
+func TestWriterProcess(t *testing.T) {
+	path := os.Getenv("NYAGOS_TEST_HISTORY")
+	if path == "" {
+		t.Skip("run by TestConcurrentWriters")
+	}
+	id := os.Getenv("NYAGOS_TEST_WRITER")
+	c := &Container{path: path}
+	for i := 0; i < writerLines; i++ {
+		row := Line{
+			Text:    fmt.Sprintf("writer%s line%d %0200d", id, i, i),
+			Stamp:   time.Now(),
+			Session: "writer" + id,
+		}
+		if err := c.Append(&row); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "nyagos-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "nyagos.history")
+
+	reader := &Container{}
+	reader.LoadTail(path, MaxSaveHistory)
+
+	cmds := []*exec.Cmd{}
+	for i := 0; i < writerCount; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestWriterProcess$")
+		cmd.Env = append(os.Environ(),
+			"NYAGOS_TEST_HISTORY="+path,
+			"NYAGOS_TEST_WRITER="+strconv.Itoa(i))
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	// Sync while writers are running must not read broken lines.
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if err := reader.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if reader.Len() >= writerCount*writerLines {
+			break
+		}
+		if time.Now().After(deadline) {
+			for _, cmd := range cmds {
+				cmd.Process.Kill()
+			}
+			t.Fatalf("Sync() read %d lines until the deadline, expected %d", reader.Len(), writerCount*writerLines)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := reader.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.Len() != writerCount*writerLines {
+		t.Fatalf("Sync() read %d lines, expected %d", reader.Len(), writerCount*writerLines)
+	}
+	count := map[string]int{}
+	for i := 0; i < reader.Len(); i++ {
+		row := reader.rows[i]
+		var id, n int
+		if _, err := fmt.Sscanf(row.Text, "writer%d line%d", &id, &n); err != nil || row.Session != fmt.Sprintf("writer%d", id) {
+			t.Fatalf("broken line: %q", row.Text)
+		}
+		count[row.Session]++
+	}
+	for i := 0; i < writerCount; i++ {
+		if n := count[fmt.Sprintf("writer%d", i)]; n != writerLines {
+			t.Fatalf("writer%d: %d lines, expected %d", i, n, writerLines)
+		}
+	}
+}
+
+func TestSyncSkipsOwnAndDuplicated(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "nyagos-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "nyagos.history")
+
+	c := &Container{}
+	c.LoadTail(path, MaxSaveHistory)
+	stamp := time.Now()
+
+	own := Line{Text: "own", Stamp: stamp, Session: SessionID}
+	c.PushLine(own)
+	c.Append(&own)
+	other := Line{Text: "other", Stamp: stamp, Session: "x"}
+	c.Append(&other)
+	c.Append(&other)
+	again := Line{Text: "other", Stamp: stamp.Add(time.Second), Session: "y"}
+	c.Append(&again)
+
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 2 || c.At(0) != "own" || c.At(1) != "other" {
+		t.Fatalf("Sync(): Len()=%d", c.Len())
+	}
+}
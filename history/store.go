@@ -0,0 +1,259 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zetamatta/nyagos/nodos"
+)
+
+// The history file is append-only. Each record is one line written by
+// Line.String() and only the tail of the file is read at startup.
+// The records are appended when the commands finish but stamped with
+// the time when they started, so they are not sorted by the timestamp
+// in the file and Query reads the whole file.
+
+const tailChunkSize = 64 * 1024
+
+// tailOffset returns the offset of the n-th line from the end of the file.
+func tailOffset(fd io.ReaderAt, size int64, n int) (int64, error) {
+	if n <= 0 {
+		return size, nil
+	}
+	buffer := make([]byte, tailChunkSize)
+	count := 0
+	end := size
+	for end > 0 {
+		start := end - tailChunkSize
+		if start < 0 {
+			start = 0
+		}
+		chunk := buffer[:end-start]
+		if _, err := fd.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			count++
+			if count >= n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// LoadTail reads the last n records of the history file.
+// The records appended later by Append are written into the same file.
+func (hisObj *Container) LoadTail(path string, n int) error {
+	hisObj.path = path
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	stat, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+	offset, err := tailOffset(fd, stat.Size(), n)
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	hisObj.LoadViaReader(fd)
+	hisObj.offset = stat.Size()
+	return nil
+}
+
+// Path returns the filename where the history is saved.
+func (hisObj *Container) Path() string {
+	return hisObj.path
+}
+
+// Append writes the record to the end of the history file.
+// The file is locked while writing not to be mixed with other sessions.
+func (hisObj *Container) Append(row *Line) error {
+	if hisObj.path == "" {
+		return nil
+	}
+	for retry := 0; ; retry++ {
+		fd, err := os.OpenFile(hisObj.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if err = nodos.LockFile(fd); err == nil {
+			// The file may be replaced by Remove of other sessions
+			// while waiting for the lock.
+			if retry < 3 && isReplaced(fd, hisObj.path) {
+				nodos.UnlockFile(fd)
+				fd.Close()
+				continue
+			}
+			_, err = io.WriteString(fd, row.String()+"\n")
+			nodos.UnlockFile(fd)
+		}
+		if err1 := fd.Close(); err == nil {
+			err = err1
+		}
+		return err
+	}
+}
+
+func isReplaced(fd *os.File, path string) bool {
+	stat1, err := fd.Stat()
+	if err != nil {
+		return false
+	}
+	stat2, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(stat1, stat2)
+}
+
+// ShareHistory is true to read the records appended by other sessions
+// before each prompt.
+var ShareHistory = false
+
+// Sync reads the records which other sessions have appended to the history
+// file since the last time. The records of this session and the duplicated
+// ones are not merged.
+func (hisObj *Container) Sync() error {
+	if hisObj.path == "" {
+		return nil
+	}
+	fd, err := os.Open(hisObj.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer fd.Close()
+	stat, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() <= hisObj.offset {
+		// When the file has been shrunk by other sessions removing
+		// records, the records appended until then are not read.
+		hisObj.offset = stat.Size()
+		return nil
+	}
+	data := make([]byte, stat.Size()-hisObj.offset)
+	n, err := fd.ReadAt(data, hisObj.offset)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	data = data[:n]
+	// The last line may not be written completely yet.
+	end := bytes.LastIndexByte(data, '\n') + 1
+	hisObj.offset += int64(end)
+
+	known := map[string]struct{}{}
+	for i := len(hisObj.rows) - 1; i >= 0 && i >= len(hisObj.rows)-MaxSaveHistory; i-- {
+		known[hisObj.rows[i].key()] = struct{}{}
+	}
+	for _, text := range strings.Split(string(data[:end]), "\n") {
+		text = strings.TrimRight(text, "\r")
+		if text == "" {
+			continue
+		}
+		row := parseLine(text)
+		if row.Session == SessionID {
+			continue
+		}
+		key := row.key()
+		if _, ok := known[key]; ok {
+			continue
+		}
+		known[key] = struct{}{}
+		if n := len(hisObj.rows); n > 0 && hisObj.rows[n-1].Text == row.Text {
+			continue
+		}
+		hisObj.rows = append(hisObj.rows, row)
+	}
+	return nil
+}
+
+// Filter is the condition to select records of history.
+type Filter struct {
+	Dir     string // the directory and its subdirectories
+	Since   time.Time
+	Failed  bool // only records whose exit code is not zero
+	Session string
+	Grep    *regexp.Regexp
+}
+
+// Match returns true when row meets all conditions of f.
+func (f *Filter) Match(row *Line) bool {
+	if f.Dir != "" {
+		dir := strings.TrimRight(f.Dir, `\/`)
+		if len(row.Dir) < len(dir) || !strings.EqualFold(row.Dir[:len(dir)], dir) {
+			return false
+		}
+		if len(row.Dir) > len(dir) && !os.IsPathSeparator(row.Dir[len(dir)]) {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && row.Stamp.Before(f.Since) {
+		return false
+	}
+	if f.Failed && row.ExitCode == 0 {
+		return false
+	}
+	if f.Session != "" && row.Session != f.Session {
+		return false
+	}
+	if f.Grep != nil && !f.Grep.MatchString(row.Text) {
+		return false
+	}
+	return true
+}
+
+// Query calls callback with the records in the history file matching f
+// from the older to the newer until callback returns false.
+func Query(path string, f *Filter, callback func(*Line) bool) error {
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		row := parseLine(sc.Text())
+		if f.Match(&row) && !callback(&row) {
+			break
+		}
+	}
+	return sc.Err()
+}
+
+// Finish sets the exit code and the duration to row, which the caller
+// has kept since it was given to PushLine, and appends it to the history
+// file. The record is not saved when it has been removed by Remove
+// before the command finishes.
+func (hisObj *Container) Finish(row *Line, exitCode int, now time.Time) error {
+	key := row.key()
+	row.ExitCode = exitCode
+	row.Duration = now.Sub(row.Stamp)
+	for i := len(hisObj.rows) - 1; i >= 0; i-- {
+		if hisObj.rows[i].key() == key {
+			hisObj.rows[i] = *row
+			return hisObj.Append(row)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package history
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLineString(t *testing.T) {
+	row := Line{
+		Text:     "make test",
+		Dir:      `C:\src`,
+		Stamp:    time.Date(2019, 3, 1, 12, 34, 56, 0, time.Local),
+		Pid:      123,
+		ExitCode: 2,
+		Duration: 1500 * time.Millisecond,
+		Host:     "host1",
+		Session:  "abc",
+	}
+	if result := parseLine(row.String()); result != row {
+		t.Fatalf("parseLine(%q) = %v", row.String(), result)
+	}
+	old := parseLine("ls\t/tmp\t2019-03-01 12:34:56\t99")
+	if old.Text != "ls" || old.Dir != "/tmp" || old.Pid != 99 || old.Session != "" {
+		t.Fatalf("parseLine(old format) = %v", old)
+	}
+}
+
+func makeHistoryFile(t *testing.T, n int, base time.Time) string {
+	tmp, err := ioutil.TempDir("", "nyagos-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tmp, "nyagos.history")
+	c := &Container{path: path}
+	for i := 0; i < n; i++ {
+		row := Line{
+			Text:     fmt.Sprintf("command %d", i),
+			Dir:      fmt.Sprintf("/work/dir%d", i%3),
+			Stamp:    base.Add(time.Duration(i) * time.Minute),
+			ExitCode: i % 5,
+			Session:  fmt.Sprintf("s%d", i%2),
+		}
+		if err := c.Append(&row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestLoadTail(t *testing.T) {
+	path := makeHistoryFile(t, 5000, time.Date(2019, 1, 1, 0, 0, 0, 0, time.Local))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	c := &Container{}
+	if err := c.LoadTail(path, 100); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 100 || c.At(0) != "command 4900" || c.At(99) != "command 4999" {
+		t.Fatalf("LoadTail: Len()=%d At(0)=%q", c.Len(), c.At(0))
+	}
+}
+
+func TestQuery(t *testing.T) {
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.Local)
+	path := makeHistoryFile(t, 5000, base)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	count := 0
+	first := ""
+	filter := &Filter{
+		Since:   base.Add(4000 * time.Minute),
+		Failed:  true,
+		Session: "s1",
+		Dir:     "/work/dir1",
+		Grep:    regexp.MustCompile(`3$`),
+	}
+	err := Query(path, filter, func(row *Line) bool {
+		if first == "" {
+			first = row.Text
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// i >= 4000, i%5 != 0, i%2 == 1, i%3 == 1 and i ends with 3 : i%30 == 13
+	if first != "command 4003" || count != 34 {
+		t.Fatalf("Query: first=%q count=%d", first, count)
+	}
+
+	// The records of the long commands which started before the others
+	// are appended after them.
+	c := &Container{path: path}
+	since := base.Add(6000 * time.Minute)
+	for i := 0; i < 3000; i++ {
+		if err := c.Append(&Line{Text: "new", Stamp: since.Add(time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3000; i++ {
+		if err := c.Append(&Line{Text: "long", Stamp: since.Add(-time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count = 0
+	err = Query(path, &Filter{Since: since}, func(row *Line) bool {
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3000 {
+		t.Fatalf("Query: count=%d for the records not sorted by the timestamp", count)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "nyagos-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "nyagos.history")
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.Local)
+
+	c := &Container{path: path}
+	pending := Line{Text: "make", Stamp: start, Session: SessionID}
+	c.PushLine(pending)
+	// the records read from other sessions while the command runs
+	c.PushLine(Line{Text: "ls", Stamp: start.Add(time.Second), Session: "other"})
+	if err := c.Finish(&pending, 2, start.Add(3*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if row := c.rows[0]; row.ExitCode != 2 || row.Duration != 3*time.Second {
+		t.Fatalf("Finish: rows[0] = %v", row)
+	}
+	if row := c.rows[1]; row.ExitCode != 0 || row.Duration != 0 {
+		t.Fatalf("Finish: rows[1] = %v", row)
+	}
+
+	// the record purged before the command finishes is not saved.
+	purged := Line{Text: "secret", Stamp: start.Add(5 * time.Second), Session: SessionID}
+	c.PushLine(purged)
+	if _, err := c.Remove(func(row *Line) bool { return row.Text == "secret" }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Finish(&purged, 0, start.Add(6*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved []string
+	err = Query(path, &Filter{}, func(row *Line) bool {
+		saved = append(saved, fmt.Sprintf("%s:%d", row.Text, row.ExitCode))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0] != "make:2" {
+		t.Fatalf("saved: %v", saved)
+	}
+}
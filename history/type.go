@@ -3,20 +3,45 @@ package history
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Line has one history data
 type Line struct {
-	Text  string
-	Dir   string
-	Stamp time.Time
-	Pid   int
+	Text     string
+	Dir      string
+	Stamp    time.Time
+	Pid      int
+	ExitCode int
+	Duration time.Duration
+	Host     string
+	Session  string
 }
 
+const stampFormat = "2006-01-02 15:04:05"
+
+// SessionID is the unique id of this process to be recorded with history.
+var SessionID = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+var hostName = func() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}()
+
 // Container has all history data.
 type Container struct {
 	rows []Line
+	path string
+	// offset is the size of the history file which has been read.
+	offset int64
+	// substOld and substNew are the last substitution by `:s/old/new/`
+	substOld string
+	substNew string
 }
 
 type packageIdT struct{}
@@ -37,6 +62,14 @@ func (c *Container) At(n int) string {
 	return c.rows[n%len(c.rows)].Text
 }
 
+// Get returns n-th history data
+func (c *Container) Get(n int) Line {
+	for n < 0 {
+		n += len(c.rows)
+	}
+	return c.rows[n%len(c.rows)]
+}
+
 // Push appends a new history line to self with string
 func (c *Container) Push(line string) {
 	c.rows = append(c.rows, Line{Text: line})
@@ -49,11 +82,43 @@ func (c *Container) PushLine(row Line) {
 
 // String returns self as printable text
 func (row *Line) String() string {
-	return fmt.Sprintf("%s\t%s\t%s\t%d",
+	return fmt.Sprintf("%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s",
 		row.Text,
 		row.Dir,
-		row.Stamp.Format("2006-01-02 15:04:05"),
-		row.Pid)
+		row.Stamp.Format(stampFormat),
+		row.Pid,
+		row.ExitCode,
+		row.Duration/time.Millisecond,
+		row.Host,
+		row.Session)
+}
+
+// key returns the text to find the same record.
+func (row *Line) key() string {
+	return row.Session + "\t" + row.Stamp.Format(stampFormat) + "\t" + row.Text
+}
+
+// parseLine reads the text made by Line.String().
+// The fields which older versions did not write are left zero.
+func parseLine(text string) Line {
+	p := strings.Split(text, "\t")
+	row := Line{Text: p[0]}
+	if len(p) >= 3 {
+		row.Dir = p[1]
+		row.Stamp, _ = time.ParseInLocation(stampFormat, p[2], time.Local)
+	}
+	if len(p) >= 4 {
+		row.Pid, _ = strconv.Atoi(p[3])
+	}
+	if len(p) >= 8 {
+		row.ExitCode, _ = strconv.Atoi(p[4])
+		if ms, err := strconv.ParseInt(p[5], 10, 64); err == nil {
+			row.Duration = time.Duration(ms) * time.Millisecond
+		}
+		row.Host = p[6]
+		row.Session = p[7]
+	}
+	return row
 }
 
 // NewHistoryLine returns new Line object with history-text
@@ -62,5 +127,34 @@ func NewHistoryLine(text string) Line {
 	if err != nil {
 		wd = ""
 	}
-	return Line{Text: text, Dir: wd, Stamp: time.Now(), Pid: os.Getpid()}
+	return Line{
+		Text:    text,
+		Dir:     wd,
+		Stamp:   time.Now(),
+		Pid:     os.Getpid(),
+		Host:    hostName,
+		Session: SessionID,
+	}
+}
+
+// Suggest returns the newest history-text which starts with prefix.
+// The text executed on dir is preferred to others.
+func (c *Container) Suggest(prefix, dir string) string {
+	if prefix == "" {
+		return ""
+	}
+	other := ""
+	for i := len(c.rows) - 1; i >= 0; i-- {
+		row := &c.rows[i]
+		if len(row.Text) <= len(prefix) || !strings.HasPrefix(row.Text, prefix) {
+			continue
+		}
+		if dir == "" || strings.EqualFold(row.Dir, dir) {
+			return row.Text
+		}
+		if other == "" {
+			other = row.Text
+		}
+	}
+	return other
 }
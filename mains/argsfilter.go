@@ -29,6 +29,22 @@ func newArgHook(ctx context.Context, it *shell.Shell, args []string) ([]string,
 		return nil, errors.New("Could not get lua instance(newArgHook)")
 	}
 	L := luawrapper.Lua
+	callLuaHooks(ctx, it, L, "argsfilter", func() []lua.LValue {
+		param := L.NewTable()
+		for i := 0; i < len(args); i++ {
+			L.SetTable(param, lua.LNumber(i), lua.LString(args[i]))
+		}
+		return []lua.LValue{param}
+	}, func(result lua.LValue) {
+		if table, ok := result.(*lua.LTable); ok {
+			size := table.Len()
+			newargs := make([]string, size+1)
+			for i := 0; i <= size; i++ {
+				newargs[i] = L.GetTable(table, lua.LNumber(i)).String()
+			}
+			args = newargs
+		}
+	})
 	nyagosTable := L.GetGlobal("nyagos")
 	if _, ok := nyagosTable.(*lua.LTable); !ok {
 		return orgArgHook(ctx, it, args)
@@ -34,12 +34,17 @@ func cloneTo(L1, L2 Lua) bool {
 		return false
 	}
 	deepCopyTable(L2, G1, G2)
+	// share[] exists on L2 already and is not overridden by deepCopyTable.
+	if share, ok := G1.RawGetString("share").(*lua.LTable); ok {
+		L2.SetGlobal("share", copyValue(L2, share, map[*lua.LTable]*lua.LTable{}))
+	}
 	return true
 }
 
 // Clone makes a copy of Lua instance.
+// The new instance is taken from the pool and the globals are copied deeply.
 func Clone(L Lua) (Lua, error) {
-	L2, err := NewLua()
+	L2, err := getLua()
 	if err != nil {
 		return L2, err
 	}
@@ -49,3 +54,123 @@ func Clone(L Lua) (Lua, error) {
 	L2.Close()
 	return nil, errors.New("could not create Lua instance")
 }
+
+// portableFunc is the copy of a Lua function which can be made on the
+// other Lua instances. A closure refers to the globals and the upvalues
+// of the instance which made it, so it must not be called on the copies
+// of the instance in the other goroutines. portableFunc keeps its
+// prototype and the snapshot of its upvalues instead.
+type portableFunc struct {
+	f        *lua.LFunction // the Go function which is shared as it is
+	proto    *lua.FunctionProto
+	upvalues []*portableUpvalue
+}
+
+// portableUpvalue is the snapshot of an upvalue. The closures sharing
+// an upvalue share it again on the instance where they are made.
+type portableUpvalue struct {
+	value lua.LValue
+	f     *portableFunc // when the value is a Lua function
+}
+
+type portableMaker struct {
+	L        Lua
+	funcs    map[*lua.LFunction]*portableFunc
+	upvalues map[*lua.Upvalue]*portableUpvalue
+	tables   map[*lua.LTable]*lua.LTable
+}
+
+// newPortableFunc makes portableFunc of f. It must be called on the
+// goroutine of the instance which owns f.
+func newPortableFunc(L Lua, f *lua.LFunction) *portableFunc {
+	m := &portableMaker{
+		L:        L,
+		funcs:    map[*lua.LFunction]*portableFunc{},
+		upvalues: map[*lua.Upvalue]*portableUpvalue{},
+		tables:   map[*lua.LTable]*lua.LTable{},
+	}
+	return m.function(f)
+}
+
+func (m *portableMaker) function(f *lua.LFunction) *portableFunc {
+	if p, ok := m.funcs[f]; ok {
+		return p
+	}
+	p := &portableFunc{}
+	m.funcs[f] = p
+	if f.IsG {
+		p.f = f
+		return p
+	}
+	p.proto = f.Proto
+	p.upvalues = make([]*portableUpvalue, len(f.Upvalues))
+	for i, uv := range f.Upvalues {
+		p.upvalues[i] = m.upvalue(uv)
+	}
+	return p
+}
+
+func (m *portableMaker) upvalue(uv *lua.Upvalue) *portableUpvalue {
+	if uv == nil {
+		return &portableUpvalue{value: lua.LNil}
+	}
+	if pv, ok := m.upvalues[uv]; ok {
+		return pv
+	}
+	pv := &portableUpvalue{}
+	m.upvalues[uv] = pv
+	if f, ok := uv.Value().(*lua.LFunction); ok {
+		pv.f = m.function(f)
+	} else {
+		pv.value = copyValue(m.L, uv.Value(), m.tables)
+	}
+	return pv
+}
+
+type portableInstance struct {
+	L        Lua
+	funcs    map[*portableFunc]*lua.LFunction
+	upvalues map[*portableUpvalue]*lua.Upvalue
+	tables   map[*lua.LTable]*lua.LTable
+}
+
+// instantiate makes the closure on L which refers to the globals of L
+// and the copies of the upvalues.
+func (p *portableFunc) instantiate(L Lua) *lua.LFunction {
+	in := &portableInstance{
+		L:        L,
+		funcs:    map[*portableFunc]*lua.LFunction{},
+		upvalues: map[*portableUpvalue]*lua.Upvalue{},
+		tables:   map[*lua.LTable]*lua.LTable{},
+	}
+	return in.function(p)
+}
+
+func (in *portableInstance) function(p *portableFunc) *lua.LFunction {
+	if p.f != nil {
+		return p.f
+	}
+	if f, ok := in.funcs[p]; ok {
+		return f
+	}
+	f := in.L.NewFunctionFromProto(p.proto)
+	in.funcs[p] = f
+	for i, pv := range p.upvalues {
+		f.Upvalues[i] = in.upvalue(pv)
+	}
+	return f
+}
+
+func (in *portableInstance) upvalue(pv *portableUpvalue) *lua.Upvalue {
+	if uv, ok := in.upvalues[pv]; ok {
+		return uv
+	}
+	uv := &lua.Upvalue{}
+	in.upvalues[pv] = uv
+	if pv.f != nil {
+		uv.SetValue(in.function(pv.f))
+	} else {
+		uv.SetValue(copyValue(in.L, pv.value, in.tables))
+	}
+	return uv
+}
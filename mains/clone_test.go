@@ -44,3 +44,40 @@ func TestClone(t *testing.T) {
 	testDestinate(t, L2)
 	L2.Close()
 }
+
+func TestPortableFunc(t *testing.T) {
+	L1 := lua.NewState()
+	defer L1.Close()
+	err := L1.DoString(`
+		local n = 10
+		local t = { x = 1 }
+		local function helper(a) return a + n end
+		f = function() n = n + 1 ; t.x = t.x + 1 ; return helper(t.x) .. name end
+		get = function() return n .. "," .. t.x end
+		name = "@main"`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	p := newPortableFunc(L1, L1.GetGlobal("f").(*lua.LFunction))
+
+	L2 := lua.NewState()
+	defer L2.Close()
+	L2.SetGlobal("name", lua.LString("@clone"))
+	f2 := p.instantiate(L2)
+	for _, expect := range []string{"13@clone", "15@clone"} {
+		L2.Push(f2)
+		if err := L2.PCall(0, 1, nil); err != nil {
+			t.Fatal(err.Error())
+		}
+		if result := L2.Get(-1).String(); result != expect {
+			t.Fatalf("f() = %s on the copy (expected %s)", result, expect)
+		}
+		L2.Pop(1)
+	}
+	if err := L1.DoString(`result = get()`); err != nil {
+		t.Fatal(err.Error())
+	}
+	if result := L1.GetGlobal("result").String(); result != "10,1" {
+		t.Fatalf("the upvalues of the main instance are changed: %s", result)
+	}
+}
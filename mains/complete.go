@@ -48,6 +48,7 @@ func luaHookForComplete(ctx context.Context, this *readline.Buffer, rv *completi
 	}
 	L.SetField(tbl, "field", field)
 	L.SetField(tbl, "left", lua.LString(rv.Left))
+	L.SetField(tbl, "role", lua.LString(rv.Role.String()))
 
 	defer setContext(L, getContext(L))
 	setContext(L, ctx)
@@ -4,6 +4,7 @@ package mains
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -73,6 +74,17 @@ func (c *customCompleter) Complete(ctx context.Context, args []string) ([]comple
 				if strings.HasPrefix(strings.ToUpper(s), base) {
 					r = append(r, completion.Element1(s))
 				}
+			} else if t, ok := val.(*lua.LTable); ok {
+				// {value, display, description}
+				s := lua.LVAsString(LL.RawGetInt(t, 1))
+				if s == "" || !strings.HasPrefix(strings.ToUpper(s), base) {
+					return
+				}
+				display := lua.LVAsString(LL.RawGetInt(t, 2))
+				if display == "" {
+					display = s
+				}
+				r = append(r, completion.Element3{s, display, lua.LVAsString(LL.RawGetInt(t, 3))})
 			}
 		})
 		return r, nil
@@ -107,6 +119,15 @@ func complete4setter(L Lua) int {
 		L.Push(lua.LTrue)
 		return 1
 	}
+	if tbl, ok := val.(*lua.LTable); ok {
+		spec, err := luaTableToSpec(L, string(key), tbl)
+		if err != nil {
+			return lerror(L, err.Error())
+		}
+		completion.CustomCompletion[string(key)] = spec
+		L.Push(lua.LTrue)
+		return 1
+	}
 	if ud, ok := val.(*lua.LUserData); ok {
 		if c, ok := ud.Value.(completion.CustomCompleter); ok {
 			completion.CustomCompletion[string(key)] = c
@@ -116,3 +137,50 @@ func complete4setter(L Lua) int {
 	}
 	return lerror(L, "nyagos.complete_for[]= not function")
 }
+
+// toJSONValue converts the value from lvalueToInterface to the one
+// which encoding/json can marshal: the table with the sequence keys becomes
+// a slice and other tables become map[string]interface{}.
+func toJSONValue(value interface{}) interface{} {
+	table, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return value
+	}
+	n := len(table)
+	if n <= 0 {
+		return nil
+	}
+	array := make([]interface{}, n)
+	isArray := true
+	for key, val := range table {
+		i, ok := key.(int)
+		if !ok || i < 1 || i > n {
+			isArray = false
+			break
+		}
+		array[i-1] = toJSONValue(val)
+	}
+	if isArray {
+		return array
+	}
+	result := make(map[string]interface{}, len(table))
+	for key, val := range table {
+		result[fmt.Sprint(key)] = toJSONValue(val)
+	}
+	return result
+}
+
+func luaTableToSpec(L Lua, name string, tbl *lua.LTable) (*completion.Spec, error) {
+	value, ok := toJSONValue(lvalueToInterface(L, tbl)).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("nyagos.complete_for[]= the spec table must have named fields")
+	}
+	if _, ok := value["name"]; !ok {
+		value["name"] = name
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return completion.ParseSpec(data)
+}
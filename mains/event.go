@@ -0,0 +1,237 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/shell"
+)
+
+// The callbacks of nyagos.after, nyagos.every and nyagos.watch are queued
+// when the timers expire, and called on the main Lua instance before
+// the prompt or while readline waits for keys, so that they never run
+// at once with the other Lua functions. The callbacks registered on the
+// copies for pipelines are made again on the main instance from
+// portableFunc in the same way as the handlers of nyagos.on.
+
+// WatchInterval is the interval to check the files by nyagos.watch
+var WatchInterval = time.Second
+
+type luaEvent struct {
+	id     int
+	f      *luaHook
+	args   []lua.LValue
+	queued bool
+	repeat bool
+	stop   func()
+}
+
+var (
+	eventMutex   sync.Mutex
+	eventLastID  int
+	eventTable   = map[int]*luaEvent{}
+	eventQueue   []*luaEvent
+	eventDeliver func()
+)
+
+// setLuaEventDeliverer sets the function called when an event is queued.
+// It should call runLuaEvents if it is safe.
+func setLuaEventDeliverer(f func()) {
+	eventMutex.Lock()
+	eventDeliver = f
+	eventMutex.Unlock()
+}
+
+func newLuaEvent(L Lua, f *lua.LFunction, repeat bool) *luaEvent {
+	h := &luaHook{f: f, owner: L, portable: newPortableFunc(L, f)}
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	eventLastID++
+	ev := &luaEvent{id: eventLastID, f: h, repeat: repeat}
+	eventTable[ev.id] = ev
+	return ev
+}
+
+func (ev *luaEvent) setStop(stop func()) {
+	eventMutex.Lock()
+	ev.stop = stop
+	eventMutex.Unlock()
+}
+
+// fire queues the event. The event already queued is not queued twice.
+func (ev *luaEvent) fire(args ...lua.LValue) {
+	eventMutex.Lock()
+	if eventTable[ev.id] != ev || ev.queued {
+		eventMutex.Unlock()
+		return
+	}
+	ev.queued = true
+	ev.args = args
+	eventQueue = append(eventQueue, ev)
+	deliver := eventDeliver
+	eventMutex.Unlock()
+	if deliver != nil {
+		deliver()
+	}
+}
+
+// runLuaEvents calls the callbacks queued on L.
+func runLuaEvents(ctx context.Context, sh *shell.Shell, L Lua) {
+	eventMutex.Lock()
+	queue := eventQueue
+	eventQueue = nil
+	events := make([]*luaEvent, 0, len(queue))
+	for _, ev := range queue {
+		ev.queued = false
+		if eventTable[ev.id] != ev {
+			continue // canceled
+		}
+		if !ev.repeat {
+			delete(eventTable, ev.id)
+		}
+		events = append(events, ev)
+	}
+	eventMutex.Unlock()
+
+	for _, ev := range events {
+		L.Push(ev.f.function(L))
+		for _, arg := range ev.args {
+			L.Push(arg)
+		}
+		if err := callCSL(ctx, sh, L, len(ev.args), 0); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}
+}
+
+func getEventArgs(L Lua) (time.Duration, *lua.LFunction, bool) {
+	ms, ok := L.Get(1).(lua.LNumber)
+	if !ok {
+		return 0, nil, false
+	}
+	f, ok := L.Get(2).(*lua.LFunction)
+	if !ok {
+		return 0, nil, false
+	}
+	return time.Duration(float64(ms) * float64(time.Millisecond)), f, true
+}
+
+// cmdAfter is `nyagos.after(MS,FUNCTION)` which calls FUNCTION once
+// after MS milliseconds.
+func cmdAfter(L Lua) int {
+	d, f, ok := getEventArgs(L)
+	if !ok {
+		return lerror(L, "nyagos.after: usage: nyagos.after(MS,FUNCTION)")
+	}
+	ev := newLuaEvent(L, f, false)
+	timer := time.AfterFunc(d, func() { ev.fire() })
+	ev.setStop(func() { timer.Stop() })
+	L.Push(lua.LNumber(ev.id))
+	return 1
+}
+
+// cmdEvery is `nyagos.every(MS,FUNCTION)` which calls FUNCTION
+// every MS milliseconds until nyagos.cancel is called.
+func cmdEvery(L Lua) int {
+	d, f, ok := getEventArgs(L)
+	if !ok || d <= 0 {
+		return lerror(L, "nyagos.every: usage: nyagos.every(MS,FUNCTION)")
+	}
+	ev := newLuaEvent(L, f, true)
+	ticker := time.NewTicker(d)
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				ev.fire()
+			case <-quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	ev.setStop(func() { close(quit) })
+	L.Push(lua.LNumber(ev.id))
+	return 1
+}
+
+type watchStat struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func getWatchStat(path string) watchStat {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return watchStat{}
+	}
+	return watchStat{exists: true, modTime: stat.ModTime(), size: stat.Size()}
+}
+
+// cmdWatch is `nyagos.watch(PATH,FUNCTION)` which calls FUNCTION(PATH)
+// when the file or the directory PATH is created, modified or removed.
+func cmdWatch(L Lua) int {
+	path, ok := L.Get(1).(lua.LString)
+	if !ok {
+		return lerror(L, "nyagos.watch: usage: nyagos.watch(PATH,FUNCTION)")
+	}
+	f, ok := L.Get(2).(*lua.LFunction)
+	if !ok {
+		return lerror(L, "nyagos.watch: usage: nyagos.watch(PATH,FUNCTION)")
+	}
+	ev := newLuaEvent(L, f, true)
+	ticker := time.NewTicker(WatchInterval)
+	quit := make(chan struct{})
+	go func(last watchStat) {
+		for {
+			select {
+			case <-ticker.C:
+				if current := getWatchStat(string(path)); current != last {
+					last = current
+					ev.fire(path)
+				}
+			case <-quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}(getWatchStat(string(path)))
+	ev.setStop(func() { close(quit) })
+	L.Push(lua.LNumber(ev.id))
+	return 1
+}
+
+// cmdCancel is `nyagos.cancel(ID)` which stops the timer or the watch
+// returned by nyagos.after, nyagos.every or nyagos.watch.
+func cmdCancel(L Lua) int {
+	id, ok := L.Get(1).(lua.LNumber)
+	if !ok {
+		return lerror(L, "nyagos.cancel: the 1st argument is not a number")
+	}
+	eventMutex.Lock()
+	ev, ok := eventTable[int(id)]
+	var stop func()
+	if ok {
+		delete(eventTable, ev.id)
+		stop = ev.stop
+	}
+	eventMutex.Unlock()
+	if !ok {
+		L.Push(lua.LFalse)
+		return 1
+	}
+	if stop != nil {
+		stop()
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
@@ -0,0 +1,88 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/shell"
+)
+
+func TestLuaEvents(t *testing.T) {
+	L, err := NewLua()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer L.Close()
+	sh := shell.New()
+	ctx := context.WithValue(context.Background(), shellKey, sh)
+	setContext(L, ctx)
+
+	dir, err := ioutil.TempDir("", "nyagos-watch")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	saveInterval := WatchInterval
+	WatchInterval = 10 * time.Millisecond
+	defer func() { WatchInterval = saveInterval }()
+
+	L.SetGlobal("dir", lua.LString(dir))
+	code := `
+		after, every, watched = 0, 0, nil
+		nyagos.after(10, function() after = after + 1 end)
+		canceled = nyagos.after(10, function() after = after + 100 end)
+		ticker = nyagos.every(10, function() every = every + 1 end)
+		nyagos.watch(dir, function(path) watched = path end)
+		nyagos.cancel(canceled)`
+	if err := L.DoString(code); err != nil {
+		t.Fatal(err.Error())
+	}
+	if L.GetGlobal("after") != lua.LNumber(0) {
+		t.Fatal("the callback was called before runLuaEvents")
+	}
+	time.Sleep(50 * time.Millisecond)
+	ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0666)
+	time.Sleep(50 * time.Millisecond)
+	runLuaEvents(ctx, sh, L)
+
+	if value := L.GetGlobal("after"); value != lua.LNumber(1) {
+		t.Errorf("after = %v", value)
+	}
+	// the ticks while the callback is queued are merged.
+	if value := L.GetGlobal("every"); value != lua.LNumber(1) {
+		t.Errorf("every = %v", value)
+	}
+	if value := L.GetGlobal("watched"); value != lua.LString(dir) {
+		t.Errorf("watched = %v", value)
+	}
+	// the callback registered on the copy for the pipeline is called
+	// on the main instance without touching the copy.
+	L2, err := Clone(L)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := L2.DoString(`nyagos.after(10, function() fromcopy = true end)`); err != nil {
+		t.Fatal(err.Error())
+	}
+	L2.Close()
+	time.Sleep(50 * time.Millisecond)
+	runLuaEvents(ctx, sh, L)
+	if L.GetGlobal("fromcopy") != lua.LTrue {
+		t.Error("the callback registered on the copy was not called on the main instance")
+	}
+
+	if err := L.DoString(`ok = nyagos.cancel(ticker) and not nyagos.cancel(ticker)`); err != nil {
+		t.Fatal(err.Error())
+	}
+	if L.GetGlobal("ok") != lua.LTrue {
+		t.Error("nyagos.cancel did not work")
+	}
+}
@@ -138,7 +138,7 @@ func cmdExec(L Lua) int {
 		if sh == nil {
 			println("nyagos.exec: warning shell is not found.")
 			sh = shell.New()
-			sh.SetTag(&luaWrapper{L})
+			sh.SetTag(&luaWrapper{Lua: L})
 			defer sh.Close()
 		}
 		errorlevel, err = sh.Interpret(ctx, string(statement))
@@ -172,7 +172,7 @@ func cmdEval(L Lua) int {
 			println("cmdEval: shell not found.")
 			defer sh.Close()
 		}
-		sh.SetTag(&luaWrapper{L})
+		sh.SetTag(&luaWrapper{Lua: L})
 		saveOut := sh.Stdout
 		sh.Stdout = w
 		sh.Interpret(ctx, statement)
@@ -0,0 +1,54 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/frame"
+)
+
+// callLineFilter calls nyagos[name](line) and returns its result.
+// When nyagos[name] is not a function, it returns defaultFunc(line).
+func callLineFilter(ctx context.Context, L Lua, name, line string, defaultFunc func(string) string) string {
+	nyagosTbl, ok := L.GetGlobal("nyagos").(*lua.LTable)
+	if !ok {
+		return defaultFunc(line)
+	}
+	f, ok := L.GetField(nyagosTbl, name).(*lua.LFunction)
+	if !ok {
+		return defaultFunc(line)
+	}
+	stackPos := L.GetTop()
+	defer L.SetTop(stackPos)
+
+	defer setContext(L, getContext(L))
+	setContext(L, ctx)
+
+	L.Push(f)
+	L.Push(lua.LString(line))
+	if err := L.PCall(1, 1, nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return defaultFunc(line)
+	}
+	if result, ok := L.Get(-1).(lua.LString); ok {
+		return string(result)
+	}
+	return ""
+}
+
+func luaHighlight(ctx context.Context, L Lua, line string) string {
+	result := callLineFilter(ctx, L, "highlighter", line, frame.Highlight)
+	if result == "" {
+		return line
+	}
+	return result
+}
+
+func luaSuggest(ctx context.Context, L Lua, line string) string {
+	return callLineFilter(ctx, L, "suggester", line, frame.Suggest)
+}
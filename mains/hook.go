@@ -0,0 +1,209 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/commands"
+	"github.com/zetamatta/nyagos/shell"
+)
+
+// The handlers registered by nyagos.on are kept on the Go side to be
+// called on all the Lua instances including the copies for pipelines.
+// On the instances other than the one which registered the handler,
+// the handler is made again from portableFunc not to touch the variables
+// of the registering instance from the other goroutines.
+
+var luaHookEvents = map[string]bool{
+	"preexec":    true,
+	"postexec":   true,
+	"chpwd":      true,
+	"exit":       true,
+	"filter":     true,
+	"argsfilter": true,
+}
+
+type luaHook struct {
+	f        *lua.LFunction
+	owner    Lua
+	portable *portableFunc
+}
+
+// function returns the handler which can be called on L.
+func (h *luaHook) function(L Lua) *lua.LFunction {
+	if L == h.owner {
+		return h.f
+	}
+	return h.portable.instantiate(L)
+}
+
+var (
+	luaHookMutex sync.Mutex
+	luaHooks     = map[string][]*luaHook{}
+)
+
+func getLuaHooks(event string) []*luaHook {
+	luaHookMutex.Lock()
+	defer luaHookMutex.Unlock()
+	return luaHooks[event]
+}
+
+// cmdOn is `nyagos.on(EVENT,FUNCTION)` which adds FUNCTION to the handlers
+// of EVENT. The handlers are called in the order of registration.
+func cmdOn(L Lua) int {
+	event, ok := L.Get(1).(lua.LString)
+	if !ok || !luaHookEvents[string(event)] {
+		return lerror(L, fmt.Sprintf("nyagos.on: unknown event `%s`", L.Get(1).String()))
+	}
+	f, ok := L.Get(2).(*lua.LFunction)
+	if !ok {
+		return lerror(L, "nyagos.on: the 2nd argument is not a function")
+	}
+	h := &luaHook{f: f, owner: L, portable: newPortableFunc(L, f)}
+	luaHookMutex.Lock()
+	// the new slice not to change the one got by getLuaHooks
+	handlers := luaHooks[string(event)]
+	luaHooks[string(event)] = append(handlers[:len(handlers):len(handlers)], h)
+	luaHookMutex.Unlock()
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// cmdOff is `nyagos.off(EVENT[,FUNCTION])` which removes FUNCTION or
+// all the handlers of EVENT.
+func cmdOff(L Lua) int {
+	event, ok := L.Get(1).(lua.LString)
+	if !ok || !luaHookEvents[string(event)] {
+		return lerror(L, fmt.Sprintf("nyagos.off: unknown event `%s`", L.Get(1).String()))
+	}
+	f, _ := L.Get(2).(*lua.LFunction)
+	luaHookMutex.Lock()
+	defer luaHookMutex.Unlock()
+	var handlers []*luaHook
+	removed := false
+	for _, h := range luaHooks[string(event)] {
+		if f == nil || h.f == f {
+			removed = true
+		} else {
+			handlers = append(handlers, h)
+		}
+	}
+	luaHooks[string(event)] = handlers
+	L.Push(lua.LBool(removed))
+	return 1
+}
+
+// callLuaHooks calls the handlers of event with args and returns
+// the first result of each handler through f.
+func callLuaHooks(ctx context.Context, sh *shell.Shell, L Lua, event string,
+	args func() []lua.LValue, f func(lua.LValue)) {
+
+	handlers := getLuaHooks(event)
+	if len(handlers) <= 0 {
+		return
+	}
+	stackPos := L.GetTop()
+	defer L.SetTop(stackPos)
+	for _, h := range handlers {
+		L.Push(h.function(L))
+		values := args()
+		for _, v := range values {
+			L.Push(v)
+		}
+		if err := callCSL(ctx, sh, L, len(values), 1); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			continue
+		}
+		if f != nil {
+			f(L.Get(-1))
+		}
+		L.Pop(1)
+	}
+}
+
+// commandsTable makes the table of the commands of line:
+// { {[0]=NAME,ARG1,ARG2...}, ... }
+func commandsTable(L Lua, line string) lua.LValue {
+	table := L.NewTable()
+	statements, err := shell.Parse(line)
+	if err != nil {
+		return table
+	}
+	for _, pipeline := range statements {
+		for _, state := range pipeline {
+			args := L.NewTable()
+			for i, arg := range state.Args {
+				L.SetTable(args, lua.LNumber(i), lua.LString(arg))
+			}
+			table.Append(args)
+		}
+	}
+	return table
+}
+
+// PreExec calls the handlers of preexec before the command-line read
+// from the interactive shell is executed.
+func (lfs *luaFilterStream) PreExec(ctx context.Context, sh *shell.Shell, line string) {
+	L := lfs.L
+	callLuaHooks(ctx, sh, L, "preexec", func() []lua.LValue {
+		return []lua.LValue{lua.LString(line), commandsTable(L, line)}
+	}, nil)
+}
+
+// PostExec calls the handlers of postexec after the command-line read
+// from the interactive shell is executed.
+func (lfs *luaFilterStream) PostExec(ctx context.Context, sh *shell.Shell, line string, rc int, d time.Duration) {
+	L := lfs.L
+	callLuaHooks(ctx, sh, L, "postexec", func() []lua.LValue {
+		status := L.NewTable()
+		for _, s := range shell.PipeStatus {
+			status.Append(lua.LNumber(s))
+		}
+		return []lua.LValue{
+			lua.LString(line),
+			lua.LNumber(rc),
+			lua.LNumber(d.Seconds()),
+			status,
+		}
+	}, nil)
+}
+
+// callChpwdHooks calls the handlers of chpwd on the Lua instance of ctx
+// when cd, pushd, popd or nyagos.chdir has changed the current directory.
+func callChpwdHooks(ctx context.Context, newDir, oldDir string) {
+	L, ok := ctx.Value(luaKey).(Lua)
+	if !ok {
+		return
+	}
+	sh, ok := ctx.Value(shellKey).(*shell.Shell)
+	if !ok {
+		return
+	}
+	callLuaHooks(ctx, sh, L, "chpwd", func() []lua.LValue {
+		return []lua.LValue{lua.LString(newDir), lua.LString(oldDir)}
+	}, nil)
+}
+
+// cmdChdir is `nyagos.chdir(DIR)`
+func cmdChdir(L Lua) int {
+	if L.GetTop() < 1 {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("directory is required"))
+		return 2
+	}
+	ctx, sh := getRegInt(L)
+	ctx = context.WithValue(ctx, luaKey, L)
+	if sh != nil {
+		ctx = context.WithValue(ctx, shellKey, sh)
+	}
+	commands.Chdir(ctx, L.Get(1).String())
+	L.Push(lua.LTrue)
+	return 1
+}
@@ -0,0 +1,121 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/commands"
+	"github.com/zetamatta/nyagos/shell"
+)
+
+func TestLuaHooks(t *testing.T) {
+	L, err := NewLua()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer L.Close()
+	sh := shell.New()
+	ctx := context.WithValue(context.Background(), shellKey, sh)
+	ctx = context.WithValue(ctx, luaKey, L)
+	setContext(L, ctx)
+
+	saveHooks, saveOnChangeDir := luaHooks, commands.OnChangeDir
+	luaHooks = map[string][]*luaHook{}
+	defer func() {
+		luaHooks, commands.OnChangeDir = saveHooks, saveOnChangeDir
+	}()
+	commands.OnChangeDir = callChpwdHooks
+
+	code := `
+		log = {}
+		nyagos.on("preexec", function(line, cmds)
+			log[#log+1] = "pre:" .. line .. ":" .. cmds[1][0] .. ":" .. cmds[2][1]
+		end)
+		nyagos.on("postexec", function(line, rc, sec, status)
+			log[#log+1] = "post:" .. rc .. ":" .. #status
+		end)
+		nyagos.on("chpwd", function(new, old)
+			log[#log+1] = "chpwd"
+		end)
+		nyagos.on("filter", function(line) return line .. "1" end)
+		nyagos.on("filter", function(line) return line .. "2" end)
+		dropped = function(line) return "dropped" end
+		nyagos.on("filter", dropped)
+		removed = nyagos.off("filter", dropped)`
+	if err := L.DoString(code); err != nil {
+		t.Fatal(err.Error())
+	}
+	if L.GetGlobal("removed") != lua.LTrue {
+		t.Error("nyagos.off could not remove the handler")
+	}
+	if err := L.DoString(`unknown = nyagos.on("unknown", print)`); err != nil {
+		t.Fatal(err.Error())
+	}
+	if L.GetGlobal("unknown") != lua.LNil {
+		t.Error("nyagos.on accepted the unknown event")
+	}
+
+	line := "x"
+	callLuaHooks(ctx, sh, L, "filter", func() []lua.LValue {
+		return []lua.LValue{lua.LString(line)}
+	}, func(result lua.LValue) {
+		line = result.String()
+	})
+	if line != "x12" {
+		t.Errorf("filter: %s", line)
+	}
+
+	// the handlers are made again on the copy for the pipeline
+	L2, err := Clone(L)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer L2.Close()
+	line = "y"
+	callLuaHooks(ctx, sh, L2, "filter", func() []lua.LValue {
+		return []lua.LValue{lua.LString(line)}
+	}, func(result lua.LValue) {
+		line = result.String()
+	})
+	if line != "y12" {
+		t.Errorf("filter on the copy: %s", line)
+	}
+
+	dir, err := ioutil.TempDir("", "nyagos-chpwd")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	lfs := &luaFilterStream{L: L, sh: sh}
+	shell.PipeStatus = []int{0, 1}
+	lfs.PreExec(ctx, sh, "a | b c")
+	if err := commands.Chdir(ctx, dir); err != nil {
+		t.Fatal(err.Error())
+	}
+	lfs.PostExec(ctx, sh, "a | b c", 1, time.Second)
+	L.SetGlobal("wd", lua.LString(wd))
+	if err := L.DoString(`nyagos.chdir(wd)`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expect := []string{"pre:a | b c:a:c", "chpwd", "post:1:2", "chpwd"}
+	log, ok := L.GetGlobal("log").(*lua.LTable)
+	if !ok || log.Len() != len(expect) {
+		t.Fatalf("log: %v", L.GetGlobal("log"))
+	}
+	for i, e := range expect {
+		if value := log.RawGetInt(i + 1).String(); value != e {
+			t.Errorf("log[%d] = %s (expected %s)", i+1, value, e)
+		}
+	}
+}
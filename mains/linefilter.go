@@ -13,7 +13,8 @@ import (
 
 type luaFilterStream struct {
 	shell.Stream
-	L Lua
+	L  Lua
+	sh *shell.Shell
 }
 
 func (lfs *luaFilterStream) ReadLine(ctx context.Context) (context.Context, string, error) {
@@ -24,6 +25,14 @@ func (lfs *luaFilterStream) ReadLine(ctx context.Context) (context.Context, stri
 
 	L := lfs.L
 
+	callLuaHooks(ctx, lfs.sh, L, "filter", func() []lua.LValue {
+		return []lua.LValue{lua.LString(line)}
+	}, func(result lua.LValue) {
+		if s, ok := result.(lua.LString); ok {
+			line = string(s)
+		}
+	})
+
 	stackPos := L.GetTop()
 	defer L.SetTop(stackPos)
 
@@ -49,6 +49,11 @@ var stringProperty = map[string]*string{
 	"version":      &frame.Version,
 }
 
+var stringListProperty = map[string]*[]string{
+	"history_ignore": &history.IgnorePatterns,
+	"history_mask":   &history.MaskPatterns,
+}
+
 var boolProperty = map[string]*bool{
 	"silentmode":        &frame.SilentMode,
 	"completion_hidden": &completion.IncludeHidden,
@@ -65,6 +70,12 @@ func nyagosGetter(L Lua) int {
 		L.Push(lua.LNumber(*ptr))
 	} else if ptr, ok := stringProperty[key]; ok {
 		L.Push(lua.LString(*ptr))
+	} else if ptr, ok := stringListProperty[key]; ok {
+		table := L.NewTable()
+		for i, s := range *ptr {
+			L.SetTable(table, lua.LNumber(i+1), lua.LString(s))
+		}
+		L.Push(table)
 	} else if ptr, ok := boolProperty[key]; ok {
 		if *ptr {
 			L.Push(lua.LTrue)
@@ -95,6 +106,23 @@ func nyagosSetter(L Lua) int {
 			return lerror(L, fmt.Sprintf("nyagos[]: val is not a string"))
 		}
 		*ptr = string(val)
+	} else if ptr, ok := stringListProperty[key]; ok {
+		table, ok := L.Get(3).(*lua.LTable)
+		if !ok {
+			return lerror(L, fmt.Sprintf("nyagos.%s: must be a table of strings", key))
+		}
+		list := []string{}
+		for i := 1; i <= table.Len(); i++ {
+			s, ok := table.RawGetInt(i).(lua.LString)
+			if !ok {
+				return lerror(L, fmt.Sprintf("nyagos.%s: must be a table of strings", key))
+			}
+			list = append(list, string(s))
+		}
+		if err := history.CheckPatterns(list); err != nil {
+			return lerror(L, fmt.Sprintf("nyagos.%s: %s", key, err.Error()))
+		}
+		*ptr = list
 	} else if ptr, ok := boolProperty[key]; ok {
 		if L.Get(3) == lua.LTrue {
 			*ptr = true
@@ -134,6 +162,7 @@ func NewLua() (Lua, error) {
 	lua.OpenPackage(L)
 	lua.OpenString(L)
 	lua.OpenTable(L)
+	setupPackagePath(L)
 
 	ioTable := openIo(L)
 	L.SetGlobal("io", ioTable)
@@ -167,14 +196,26 @@ func NewLua() (Lua, error) {
 
 	L.SetField(nyagosTable, "lines", L.GetField(ioTable, "lines"))
 	L.SetField(nyagosTable, "open", L.GetField(ioTable, "open"))
-	L.SetField(nyagosTable, "loadfile", L.GetGlobal("loadfile"))
+	L.SetField(nyagosTable, "loadfile", L.NewFunction(cmdLoadFile))
+	L.SetField(nyagosTable, "chdir", L.NewFunction(cmdChdir))
 
 	keyTable := makeVirtualTable(L, lua2cmd(functions.CmdGetBindKey), cmdBindKey)
 	L.SetField(nyagosTable, "key", keyTable)
 	L.SetField(nyagosTable, "bindkey", L.NewFunction(cmdBindKey))
 	L.SetField(nyagosTable, "exec", L.NewFunction(cmdExec))
 	L.SetField(nyagosTable, "eval", L.NewFunction(cmdEval))
+	L.SetField(nyagosTable, "spawn", L.NewFunction(cmdSpawn))
+	L.SetField(nyagosTable, "after", L.NewFunction(cmdAfter))
+	L.SetField(nyagosTable, "every", L.NewFunction(cmdEvery))
+	L.SetField(nyagosTable, "watch", L.NewFunction(cmdWatch))
+	L.SetField(nyagosTable, "cancel", L.NewFunction(cmdCancel))
+	L.SetField(nyagosTable, "on", L.NewFunction(cmdOn))
+	L.SetField(nyagosTable, "off", L.NewFunction(cmdOff))
 	L.SetField(nyagosTable, "prompt", L.NewFunction(lua2param(functions.Prompt)))
+	L.SetField(nyagosTable, "prompt_segment", L.NewFunction(cmdPromptSegment))
+	L.SetField(nyagosTable, "shared", makeVirtualTable(L, cmdGetShared, cmdSetShared))
+	L.SetField(nyagosTable, "lock", L.NewFunction(cmdLock))
+	L.SetField(nyagosTable, "channel", L.NewFunction(cmdChannel))
 	L.SetField(nyagosTable, "create_object", L.NewFunction(ole.CreateObject))
 	L.SetField(nyagosTable, "to_ole_integer", L.NewFunction(ole.ToOleInteger))
 	L.SetField(nyagosTable, "goarch", lua.LString(runtime.GOARCH))
@@ -501,6 +542,7 @@ func luaRedirect(ctx context.Context, _stdin io.Reader, _stdout, _stderr io.Writ
 }
 
 func callCSL(ctx context.Context, sh *shell.Shell, L Lua, nargs, nresult int) error {
+	mergeShare(L)
 	defer setContext(L, getContext(L))
 	ctx = context.WithValue(ctx, shellKey, sh)
 	setContext(L, ctx)
@@ -0,0 +1,407 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/zetamatta/nyagos/frame"
+)
+
+// The chunks compiled from the Lua files loaded by require, use and
+// nyagos.loadfile are kept on memory to share them between the Lua
+// instances, and saved into LuacCacheDir to skip compiling on the next
+// startup. They are compiled again when the modification time or the
+// size of the source file changes.
+
+// LuacCacheDir is the directory to save the compiled chunks.
+// When it is "", they are not saved.
+var LuacCacheDir = ""
+
+// luacFormat is changed when the format of the cache file changes.
+const luacFormat = 1
+
+type luacConst struct {
+	IsString bool
+	String   string
+	Number   float64
+}
+
+type luacProto struct {
+	SourceName         string
+	LineDefined        int
+	LastLineDefined    int
+	NumUpvalues        uint8
+	NumParameters      uint8
+	IsVarArg           uint8
+	NumUsedRegisters   uint8
+	Code               []uint32
+	Constants          []luacConst
+	FunctionPrototypes []*luacProto
+	DbgSourcePositions []int
+	DbgLocals          []*lua.DbgLocalInfo
+	DbgCalls           []lua.DbgCall
+	DbgUpvalues        []string
+}
+
+type luacFile struct {
+	Format  int
+	Source  string
+	ModTime int64
+	Size    int64
+	Proto   *luacProto
+}
+
+func protoToLuac(p *lua.FunctionProto) (*luacProto, error) {
+	c := &luacProto{
+		SourceName:         p.SourceName,
+		LineDefined:        p.LineDefined,
+		LastLineDefined:    p.LastLineDefined,
+		NumUpvalues:        p.NumUpvalues,
+		NumParameters:      p.NumParameters,
+		IsVarArg:           p.IsVarArg,
+		NumUsedRegisters:   p.NumUsedRegisters,
+		Code:               p.Code,
+		DbgSourcePositions: p.DbgSourcePositions,
+		DbgLocals:          p.DbgLocals,
+		DbgCalls:           p.DbgCalls,
+		DbgUpvalues:        p.DbgUpvalues,
+	}
+	for _, value := range p.Constants {
+		switch v := value.(type) {
+		case lua.LString:
+			c.Constants = append(c.Constants, luacConst{IsString: true, String: string(v)})
+		case lua.LNumber:
+			c.Constants = append(c.Constants, luacConst{Number: float64(v)})
+		default:
+			return nil, fmt.Errorf("constant of %s is not supported", value.Type().String())
+		}
+	}
+	for _, sub := range p.FunctionPrototypes {
+		subc, err := protoToLuac(sub)
+		if err != nil {
+			return nil, err
+		}
+		c.FunctionPrototypes = append(c.FunctionPrototypes, subc)
+	}
+	return c, nil
+}
+
+// constantsChunk makes the chunk which loads the constants in the order
+// of values, so that the compiled function has the same Constants.
+func constantsChunk(values []lua.LValue) []ast.Stmt {
+	stmts := []ast.Stmt{&ast.LocalAssignStmt{Names: []string{"_"}, Exprs: []ast.Expr{}}}
+	for _, value := range values {
+		var expr ast.Expr
+		switch v := value.(type) {
+		case lua.LString:
+			expr = &ast.StringExpr{Value: string(v)}
+		case lua.LNumber:
+			expr = &ast.NumberExpr{Value: strconv.FormatFloat(float64(v), 'g', -1, 64)}
+		default:
+			return nil
+		}
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: []ast.Expr{&ast.IdentExpr{Value: "_"}},
+			Rhs: []ast.Expr{expr},
+		})
+	}
+	return stmts
+}
+
+// luacToProto rebuilds FunctionProto from the cache. The VM requires
+// the string table of the constants which is not exported and made only by
+// lua.Compile, so the function loading the same constants is compiled and
+// the other members are replaced with those of the cache.
+func luacToProto(c *luacProto) (*lua.FunctionProto, error) {
+	constants := make([]lua.LValue, len(c.Constants))
+	for i, k := range c.Constants {
+		if k.IsString {
+			constants[i] = lua.LString(k.String)
+		} else {
+			constants[i] = lua.LNumber(k.Number)
+		}
+	}
+	p, err := lua.Compile(constantsChunk(constants), c.SourceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Constants) != len(constants) {
+		return nil, errors.New("constants are not rebuilt")
+	}
+	for i, value := range p.Constants {
+		if value != constants[i] {
+			return nil, errors.New("constants are not rebuilt")
+		}
+	}
+	p.LineDefined = c.LineDefined
+	p.LastLineDefined = c.LastLineDefined
+	p.NumUpvalues = c.NumUpvalues
+	p.NumParameters = c.NumParameters
+	p.IsVarArg = c.IsVarArg
+	p.NumUsedRegisters = c.NumUsedRegisters
+	p.Code = c.Code
+	p.FunctionPrototypes = make([]*lua.FunctionProto, len(c.FunctionPrototypes))
+	p.DbgSourcePositions = c.DbgSourcePositions
+	p.DbgLocals = c.DbgLocals
+	p.DbgCalls = c.DbgCalls
+	p.DbgUpvalues = c.DbgUpvalues
+	for i, sub := range c.FunctionPrototypes {
+		subp, err := luacToProto(sub)
+		if err != nil {
+			return nil, err
+		}
+		p.FunctionPrototypes[i] = subp
+	}
+	return p, nil
+}
+
+type luacEntry struct {
+	modTime int64
+	size    int64
+	proto   *lua.FunctionProto
+}
+
+var (
+	luacMutex sync.Mutex
+	luacTable = map[string]*luacEntry{}
+)
+
+func luacCachePath(source string) string {
+	return filepath.Join(LuacCacheDir, fmt.Sprintf("%x.luac", sha1.Sum([]byte(source))))
+}
+
+func readLuacCache(source string, stat os.FileInfo) (*lua.FunctionProto, bool) {
+	if LuacCacheDir == "" {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(luacCachePath(source))
+	if err != nil {
+		return nil, false
+	}
+	var file luacFile
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&file); err != nil {
+		return nil, false
+	}
+	if file.Format != luacFormat || file.Source != source ||
+		file.ModTime != stat.ModTime().UnixNano() || file.Size != stat.Size() {
+		return nil, false
+	}
+	proto, err := luacToProto(file.Proto)
+	if err != nil {
+		return nil, false
+	}
+	return proto, true
+}
+
+func writeLuacCache(source string, stat os.FileInfo, proto *lua.FunctionProto) error {
+	if LuacCacheDir == "" {
+		return nil
+	}
+	c, err := protoToLuac(proto)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	err = gob.NewEncoder(&buffer).Encode(&luacFile{
+		Format:  luacFormat,
+		Source:  source,
+		ModTime: stat.ModTime().UnixNano(),
+		Size:    stat.Size(),
+		Proto:   c,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(LuacCacheDir, 0777); err != nil {
+		return err
+	}
+	// written into the temporary file and renamed not to let the other
+	// processes read the cache being written.
+	tmp, err := ioutil.TempFile(LuacCacheDir, ".luac")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buffer.Bytes())
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), luacCachePath(source))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func compileLuaFile(path string) (*lua.FunctionProto, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// the first line for the unix executable is skipped
+	// leaving its newline not to change the line numbers.
+	if len(data) > 0 && data[0] == '#' {
+		if n := bytes.IndexByte(data, '\n'); n >= 0 {
+			data = data[n:]
+		} else {
+			data = nil
+		}
+	}
+	chunk, err := parse.Parse(bytes.NewReader(data), path)
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, path)
+}
+
+// loadLuaProto returns the compiled chunk of path from the cache
+// or compiles it.
+func loadLuaProto(path string) (*lua.FunctionProto, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	source, err := filepath.Abs(path)
+	if err != nil {
+		source = path
+	}
+	modTime := stat.ModTime().UnixNano()
+
+	luacMutex.Lock()
+	entry, ok := luacTable[source]
+	luacMutex.Unlock()
+	if ok && entry.modTime == modTime && entry.size == stat.Size() {
+		return entry.proto, nil
+	}
+	proto, ok := readLuacCache(source, stat)
+	if !ok {
+		proto, err = compileLuaFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeLuacCache(source, stat, proto); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", luacCachePath(source), err.Error())
+		}
+	}
+	luacMutex.Lock()
+	luacTable[source] = &luacEntry{modTime: modTime, size: stat.Size(), proto: proto}
+	luacMutex.Unlock()
+	return proto, nil
+}
+
+// loadLuaFile is the cached version of L.LoadFile
+func loadLuaFile(L Lua, path string) (*lua.LFunction, error) {
+	proto, err := loadLuaProto(path)
+	if err != nil {
+		return nil, err
+	}
+	return L.NewFunctionFromProto(proto), nil
+}
+
+// doLuaFile is the cached version of L.DoFile
+func doLuaFile(L Lua, path string) error {
+	f, err := loadLuaFile(L, path)
+	if err != nil {
+		return err
+	}
+	L.Push(f)
+	return L.PCall(0, lua.MultRet, nil)
+}
+
+// cmdLoadFile is `nyagos.loadfile(PATH)` which returns the function
+// of the chunk of PATH or nil and the error message.
+func cmdLoadFile(L Lua) int {
+	path, ok := L.Get(1).(lua.LString)
+	if !ok {
+		return lerror(L, "nyagos.loadfile: the 1st argument is not a string")
+	}
+	f, err := loadLuaFile(L, string(path))
+	if err != nil {
+		return lerror(L, err.Error())
+	}
+	L.Push(f)
+	return 1
+}
+
+// luaLoaderCached is the loader of package.loaders which finds the module
+// from package.path as the original one and loads it through the cache.
+func luaLoaderCached(L Lua) int {
+	name := L.CheckString(1)
+	path, ok := L.GetField(L.GetGlobal("package"), "path").(lua.LString)
+	if !ok {
+		L.RaiseError("package.path must be a string")
+	}
+	fname := strings.Replace(name, ".", string(os.PathSeparator), -1)
+	messages := []string{}
+	for _, pattern := range strings.Split(string(path), ";") {
+		if pattern == "" {
+			continue
+		}
+		luapath := strings.Replace(pattern, "?", fname, -1)
+		if _, err := os.Stat(luapath); err != nil {
+			messages = append(messages, err.Error())
+			continue
+		}
+		f, err := loadLuaFile(L, luapath)
+		if err != nil {
+			L.RaiseError("%s", err.Error())
+		}
+		L.Push(f)
+		return 1
+	}
+	L.Push(lua.LString(strings.Join(messages, "\n\t")))
+	return 1
+}
+
+// setupPackagePath adds the directories for the modules of the user and
+// nyagos.exe to package.path and replaces the loader of the Lua files.
+func setupPackagePath(L Lua) {
+	packageTable, ok := L.GetGlobal("package").(*lua.LTable)
+	if !ok {
+		return
+	}
+	if loaders, ok := L.GetField(packageTable, "loaders").(*lua.LTable); ok {
+		L.RawSetInt(loaders, 2, L.NewFunction(luaLoaderCached))
+	}
+	dirs := []string{filepath.Join(frame.AppDataDir(), "lua")}
+	if exeName, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Join(filepath.Dir(exeName), "lua"))
+	}
+	path := L.GetField(packageTable, "path").String()
+	patterns := strings.Split(path, ";")
+	newPatterns := []string{}
+	for _, dir := range dirs {
+		for _, pattern := range []string{
+			filepath.Join(dir, "?.lua"),
+			filepath.Join(dir, "?", "init.lua"),
+		} {
+			found := false
+			for _, p := range patterns {
+				if strings.EqualFold(p, pattern) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				newPatterns = append(newPatterns, pattern)
+			}
+		}
+	}
+	L.SetField(packageTable, "path", lua.LString(strings.Join(append(newPatterns, patterns...), ";")))
+}
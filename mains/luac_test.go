@@ -0,0 +1,92 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestLuacCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nyagos-luac")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	saveDir := LuacCacheDir
+	LuacCacheDir = filepath.Join(dir, "cache")
+	defer func() { LuacCacheDir = saveDir }()
+
+	module := filepath.Join(dir, "mymod.lua")
+	code := "#!/usr/bin/env lua\nlocal M = {}\nfunction M.f(x) counter = (counter or 0) + 1 ; return x .. \"!\" .. 1.5 end\nreturn M\n"
+	if err := ioutil.WriteFile(module, []byte(code), 0666); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	run := func(expect string) {
+		L, err := NewLua()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer L.Close()
+		L.SetField(L.GetGlobal("package"), "path", lua.LString(filepath.Join(dir, "?.lua")))
+		if err := L.DoString(`result = require("mymod").f("a") .. counter`); err != nil {
+			t.Fatal(err.Error())
+		}
+		if value := L.GetGlobal("result").String(); value != expect {
+			t.Fatalf("result = %s (expected %s)", value, expect)
+		}
+	}
+	run("a!1.51")
+	if _, err := os.Stat(luacCachePath(module)); err != nil {
+		t.Fatalf("cache is not written: %s", err.Error())
+	}
+
+	// loaded from the cache file
+	luacTable = map[string]*luacEntry{}
+	if stat, err := os.Stat(module); err == nil {
+		if _, ok := readLuacCache(module, stat); !ok {
+			t.Fatal("readLuacCache failed")
+		}
+	}
+	run("a!1.51")
+
+	// compiled again when the source changes
+	code = "return { f = function(x) counter = 10 ; return x end }\n"
+	if err := ioutil.WriteFile(module, []byte(code), 0666); err != nil {
+		t.Fatal(err.Error())
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(module, future, future)
+	run("a10")
+}
+
+func TestLuacRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "nyagos.d", "*.lua"))
+	if err != nil || len(files) <= 0 {
+		t.Skip("nyagos.d not found")
+	}
+	for _, fname := range files {
+		proto, err := compileLuaFile(fname)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, err := protoToLuac(proto)
+		if err != nil {
+			t.Fatalf("%s: %s", fname, err.Error())
+		}
+		proto2, err := luacToProto(c)
+		if err != nil {
+			t.Fatalf("%s: %s", fname, err.Error())
+		}
+		if proto.String() != proto2.String() {
+			t.Fatalf("%s: the chunk is not rebuilt", fname)
+		}
+	}
+}
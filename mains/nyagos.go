@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/mattn/go-isatty"
 	"github.com/yuin/gopher-lua"
@@ -95,6 +96,7 @@ func (this *ScriptEngineForOptionImpl) RunString(ctx context.Context, code strin
 
 type luaWrapper struct {
 	Lua
+	share *lua.LTable // the copy of share[] when cloned
 }
 
 func (this *luaWrapper) Clone(ctx context.Context) (context.Context, shell.CloneCloser, error) {
@@ -103,10 +105,13 @@ func (this *luaWrapper) Clone(ctx context.Context) (context.Context, shell.Clone
 		return nil, nil, err
 	}
 	ctx = context.WithValue(ctx, luaKey, newL)
-	return ctx, &luaWrapper{newL}, nil
+	return ctx, &luaWrapper{Lua: newL, share: snapshotShare(newL)}, nil
 }
 
 func (this *luaWrapper) Close() error {
+	if this.share != nil {
+		pushShareChanges(this.Lua, this.share)
+	}
 	this.Lua.Close()
 	return nil
 }
@@ -122,21 +127,26 @@ func Main() error {
 	} else {
 		ctx = context.WithValue(ctx, luaKey, L)
 		defer L.Close()
+		mainLua = L
+		defer closeLuaPool()
+		commands.OnChangeDir = callChpwdHooks
 	}
 
 	sh := shell.New()
 	if L != nil {
-		sh.SetTag(&luaWrapper{L})
+		sh.SetTag(&luaWrapper{Lua: L})
 	}
 	defer sh.Close()
 	sh.Console = nodos.GetConsole()
 	ctx = context.WithValue(ctx, shellKey, sh)
 
+	LuacCacheDir = filepath.Join(frame.AppDataDir(), "luac")
+
 	langEngine := func(fname string) ([]byte, error) {
 		ctxTmp := context.WithValue(ctx, shellKey, sh)
 		defer setContext(L, getContext(L))
 		setContext(L, ctxTmp)
-		return nil, L.DoFile(fname)
+		return nil, doLuaFile(L, fname)
 	}
 	shellEngine := func(fname string) error {
 		return sh.Source(ctx, fname)
@@ -159,6 +169,9 @@ func Main() error {
 			fmt.Fprintln(os.Stderr, err.Error())
 		}
 	}
+	if L != nil {
+		fillLuaPool()
+	}
 
 	if script != nil {
 		if err := script(ctx); err != nil {
@@ -189,14 +202,35 @@ func Main() error {
 				}
 			})
 		stream1 = constream
+		defer constream.Close()
 		frame.DefaultHistory = constream.History
 		ctx = context.WithValue(ctx, history.PackageId, constream.History)
 		ctx = context.WithValue(ctx, shellKey, sh)
+		if L != nil {
+			constream.Editor.Highlighter = func(line string) string {
+				return luaHighlight(ctx, L, line)
+			}
+			constream.Editor.Suggester = func(line string) string {
+				return luaSuggest(ctx, L, line)
+			}
+			constream.Editor.RightPrompt = func() (string, int) {
+				return luaRightPrompt(ctx, L)
+			}
+			constream.Editor.TransientPrompt = func() (string, int) {
+				return luaTransientPrompt(ctx, L)
+			}
+			setLuaEventDeliverer(func() {
+				constream.Editor.Interrupt(func() {
+					runLuaEvents(ctx, sh, L)
+				})
+			})
+		}
 	} else {
 		stream1 = shell.NewCmdStreamFile(os.Stdin)
 	}
 	if L != nil {
-		sh.ForEver(ctx, &luaFilterStream{Stream: stream1, L: L})
+		sh.ForEver(ctx, &luaFilterStream{Stream: stream1, L: L, sh: sh})
+		callLuaHooks(ctx, sh, L, "exit", func() []lua.LValue { return nil }, nil)
 	} else {
 		sh.ForEver(ctx, stream1)
 	}
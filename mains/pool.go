@@ -0,0 +1,80 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"sync"
+)
+
+// LuaPoolSize is the number of the Lua instances initialized in advance
+// for the pipelines and the background jobs.
+var LuaPoolSize = 2
+
+// luaPool keeps the fresh instances made by NewLua in the background
+// because NewLua takes a long time.
+// The instances are not reused after Close since their globals are dirty.
+// The pool removes only the cost of NewLua: Clone still copies the globals
+// of the main instance with deepCopyTable each time.
+var luaPool struct {
+	sync.Mutex
+	idle    []Lua
+	filling bool
+}
+
+// fillLuaPool starts making the instances until LuaPoolSize in the background.
+func fillLuaPool() {
+	luaPool.Lock()
+	defer luaPool.Unlock()
+	if luaPool.filling || len(luaPool.idle) >= LuaPoolSize {
+		return
+	}
+	luaPool.filling = true
+	go func() {
+		for {
+			luaPool.Lock()
+			if len(luaPool.idle) >= LuaPoolSize {
+				luaPool.filling = false
+				luaPool.Unlock()
+				return
+			}
+			luaPool.Unlock()
+
+			L, err := NewLua()
+			luaPool.Lock()
+			if err != nil {
+				luaPool.filling = false
+				luaPool.Unlock()
+				return
+			}
+			luaPool.idle = append(luaPool.idle, L)
+			luaPool.Unlock()
+		}
+	}()
+}
+
+// getLua returns an instance from the pool or makes a new one
+// when the pool is empty.
+func getLua() (Lua, error) {
+	luaPool.Lock()
+	var L Lua
+	if n := len(luaPool.idle); n > 0 {
+		L = luaPool.idle[n-1]
+		luaPool.idle = luaPool.idle[:n-1]
+	}
+	luaPool.Unlock()
+	defer fillLuaPool()
+	if L != nil {
+		return L, nil
+	}
+	return NewLua()
+}
+
+// closeLuaPool closes the instances not used.
+func closeLuaPool() {
+	luaPool.Lock()
+	defer luaPool.Unlock()
+	for _, L := range luaPool.idle {
+		L.Close()
+	}
+	luaPool.idle = nil
+}
@@ -8,11 +8,14 @@ import (
 	"os"
 
 	"github.com/yuin/gopher-lua"
+	"github.com/zetamatta/nyagos/frame"
 	"github.com/zetamatta/nyagos/functions"
 	"github.com/zetamatta/nyagos/shell"
 )
 
 func printPrompt(ctx context.Context, sh *shell.Shell, L Lua) (int, error) {
+	mergeShare(L)
+	runLuaEvents(ctx, sh, L)
 	nyagosTbl := L.GetGlobal("nyagos")
 	prompt := L.GetField(nyagosTbl, "prompt")
 	if promptHook, ok := prompt.(*lua.LFunction); ok {
@@ -38,3 +41,17 @@ func printPrompt(ctx context.Context, sh *shell.Shell, L Lua) (int, error) {
 	}
 	return functions.PromptCore(sh.Term(), promptStr), nil
 }
+
+func asIs(s string) string { return s }
+
+// luaRightPrompt formats the result of nyagos.rprompt(%RPROMPT%)
+// or %RPROMPT% when nyagos.rprompt is not a function.
+func luaRightPrompt(ctx context.Context, L Lua) (string, int) {
+	return frame.RightPrompt(callLineFilter(ctx, L, "rprompt", os.Getenv("RPROMPT"), asIs))
+}
+
+// luaTransientPrompt formats the result of
+// nyagos.transient_prompt(%TRANSIENT_PROMPT%) like luaRightPrompt.
+func luaTransientPrompt(ctx context.Context, L Lua) (string, int) {
+	return frame.TransientPrompt(callLineFilter(ctx, L, "transient_prompt", os.Getenv("TRANSIENT_PROMPT"), asIs))
+}
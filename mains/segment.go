@@ -0,0 +1,88 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/frame"
+	"github.com/zetamatta/nyagos/shell"
+)
+
+// luaSegment runs the Lua function of the prompt segment on the copy of
+// the Lua instance, because it is called in the other goroutine.
+// The function is made again on the copy not to touch the main instance.
+type luaSegment struct {
+	sync.Mutex
+	L  Lua
+	sh *shell.Shell
+	f  *lua.LFunction
+}
+
+func (this *luaSegment) call(ctx context.Context) string {
+	this.Lock()
+	defer this.Unlock()
+	if this.L == nil {
+		return ""
+	}
+	stackPos := this.L.GetTop()
+	defer this.L.SetTop(stackPos)
+
+	ctx = context.WithValue(ctx, luaKey, this.L)
+	this.L.Push(this.f)
+	if err := callCSL(ctx, this.sh, this.L, 0, 1); err != nil {
+		return err.Error()
+	}
+	if result := this.L.Get(-1); result != lua.LNil {
+		return result.String()
+	}
+	return ""
+}
+
+func (this *luaSegment) close() {
+	this.Lock()
+	defer this.Unlock()
+	if this.L != nil {
+		this.L.Close()
+		this.L = nil
+	}
+}
+
+var luaSegments = map[string]*luaSegment{}
+
+// cmdPromptSegment is `nyagos.prompt_segment(NAME,FUNCTION)` which
+// registers FUNCTION as the provider of `${NAME}` in %PROMPT%.
+// FUNCTION is nil to remove it.
+func cmdPromptSegment(L Lua) int {
+	name, ok := L.Get(1).(lua.LString)
+	if !ok {
+		return lerror(L, "nyagos.prompt_segment: the 1st argument is not a string")
+	}
+	if old, ok := luaSegments[string(name)]; ok {
+		delete(luaSegments, string(name))
+		old.close()
+	}
+	f, ok := L.Get(2).(*lua.LFunction)
+	if !ok {
+		if L.Get(2) != lua.LNil {
+			return lerror(L, "nyagos.prompt_segment: the 2nd argument is not a function")
+		}
+		frame.RegisterPromptSegment(string(name), nil)
+		L.Push(lua.LTrue)
+		return 1
+	}
+	newL, err := Clone(L)
+	if err != nil {
+		return lerror(L, err.Error())
+	}
+	sh := shell.New()
+	sh.SetTag(&luaWrapper{Lua: newL})
+	segment := &luaSegment{L: newL, sh: sh, f: newPortableFunc(L, f).instantiate(newL)}
+	luaSegments[string(name)] = segment
+	frame.RegisterPromptSegment(string(name), segment.call)
+	L.Push(lua.LTrue)
+	return 1
+}
@@ -0,0 +1,254 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// copyValue copies val to the new value owned by L.
+// Tables and their metatables are copied deeply and the other values
+// are shared.
+func copyValue(L Lua, val lua.LValue, done map[*lua.LTable]*lua.LTable) lua.LValue {
+	t1, ok := val.(*lua.LTable)
+	if !ok {
+		return val
+	}
+	if t2, ok := done[t1]; ok {
+		return t2
+	}
+	t2 := L.NewTable()
+	done[t1] = t2
+	t1.ForEach(func(key, value lua.LValue) {
+		L.RawSet(t2, copyValue(L, key, done), copyValue(L, value, done))
+	})
+	if meta, ok := L.GetMetatable(t1).(*lua.LTable); ok {
+		L.SetMetatable(t2, copyValue(L, meta, done))
+	}
+	return t2
+}
+
+// isSharable returns false when val or the tables in val have the values
+// which can not be used by the other instances: functions, userdata and
+// coroutines.
+func isSharable(val lua.LValue, done map[*lua.LTable]bool) bool {
+	switch v := val.(type) {
+	case *lua.LFunction, *lua.LUserData, *lua.LState:
+		return false
+	case *lua.LTable:
+		if done[v] {
+			return true
+		}
+		done[v] = true
+		sharable := true
+		v.ForEach(func(key, value lua.LValue) {
+			if sharable && (!isSharable(key, done) || !isSharable(value, done)) {
+				sharable = false
+			}
+		})
+		return sharable && isSharable(v.Metatable, done)
+	}
+	return true
+}
+
+// equalValue compares the tables by their contents.
+func equalValue(v1, v2 lua.LValue) bool {
+	t1, ok1 := v1.(*lua.LTable)
+	t2, ok2 := v2.(*lua.LTable)
+	if !ok1 || !ok2 {
+		return v1 == v2
+	}
+	if t1 == t2 {
+		return true
+	}
+	equal := true
+	count := 0
+	t1.ForEach(func(key, value lua.LValue) {
+		count++
+		if equal && !equalValue(value, t2.RawGet(key)) {
+			equal = false
+		}
+	})
+	if !equal {
+		return false
+	}
+	t2.ForEach(func(lua.LValue, lua.LValue) { count-- })
+	return count == 0
+}
+
+// The members of `share[]` assigned by the Lua instances of the pipelines
+// are copied back to the main instance when they finish. They can not be
+// set to the main instance at once since it may be running on the other
+// goroutine, so they wait until mergeShare is called by the main instance.
+
+type shareChange struct {
+	key   lua.LValue
+	value lua.LValue
+}
+
+var (
+	shareChangeMutex sync.Mutex
+	shareChanges     []shareChange
+)
+
+// mainLua is the instance which the changes of `share[]` are merged to.
+var mainLua Lua
+
+func getShareTable(L Lua) (*lua.LTable, bool) {
+	share, ok := L.GetGlobal("share").(*lua.LTable)
+	return share, ok
+}
+
+// snapshotShare copies `share[]` to compare it with the table after
+// the instance finishes.
+func snapshotShare(L Lua) *lua.LTable {
+	share, ok := getShareTable(L)
+	if !ok {
+		return nil
+	}
+	return copyValue(L, share, map[*lua.LTable]*lua.LTable{}).(*lua.LTable)
+}
+
+// pushShareChanges queues the members of `share[]` which differ from
+// the snapshot.
+func pushShareChanges(L Lua, snapshot *lua.LTable) {
+	share, ok := getShareTable(L)
+	if !ok || snapshot == nil {
+		return
+	}
+	var changes []shareChange
+	share.ForEach(func(key, value lua.LValue) {
+		if !equalValue(value, snapshot.RawGet(key)) {
+			changes = append(changes, shareChange{key: key, value: value})
+		}
+	})
+	snapshot.ForEach(func(key, _ lua.LValue) {
+		if share.RawGet(key) == lua.LNil {
+			changes = append(changes, shareChange{key: key, value: lua.LNil})
+		}
+	})
+	if len(changes) <= 0 {
+		return
+	}
+	shareChangeMutex.Lock()
+	shareChanges = append(shareChanges, changes...)
+	shareChangeMutex.Unlock()
+}
+
+// mergeShare sets the members queued by pushShareChanges to `share[]` of L.
+func mergeShare(L Lua) {
+	if L != mainLua {
+		return
+	}
+	shareChangeMutex.Lock()
+	changes := shareChanges
+	shareChanges = nil
+	shareChangeMutex.Unlock()
+	if len(changes) <= 0 {
+		return
+	}
+	share, ok := getShareTable(L)
+	if !ok {
+		return
+	}
+	done := map[*lua.LTable]*lua.LTable{}
+	for _, c := range changes {
+		L.RawSet(share, c.key, copyValue(L, c.value, done))
+	}
+}
+
+// nyagos.shared[] is the table whose members are shared by all Lua
+// instances at once. The values are copied on each access under the lock.
+
+var (
+	sharedMutex sync.Mutex
+	sharedTable = &lua.LTable{Metatable: lua.LNil}
+)
+
+func cmdGetShared(L Lua) int {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+	value := sharedTable.RawGet(L.Get(2))
+	L.Push(copyValue(L, value, map[*lua.LTable]*lua.LTable{}))
+	return 1
+}
+
+// cmdSetShared raises the error since the results of __newindex
+// are ignored.
+func cmdSetShared(L Lua) int {
+	key := L.Get(2)
+	if key == lua.LNil {
+		L.RaiseError("nyagos.shared[]: the key is nil")
+	}
+	if !isSharable(L.Get(3), map[*lua.LTable]bool{}) {
+		L.RaiseError("nyagos.shared[]: functions and userdata can not be shared")
+	}
+	value := copyValue(L, L.Get(3), map[*lua.LTable]*lua.LTable{})
+	sharedMutex.Lock()
+	sharedTable.RawSet(key, value)
+	sharedMutex.Unlock()
+	return 0
+}
+
+var (
+	namedMutex   sync.Mutex
+	namedLocks   = map[string]*sync.Mutex{}
+	namedChannel = map[string]lua.LChannel{}
+)
+
+// cmdLock is `nyagos.lock(NAME,FUNCTION,...)` which calls FUNCTION while
+// it holds the lock named NAME and returns the results of FUNCTION.
+func cmdLock(L Lua) int {
+	name, ok := L.Get(1).(lua.LString)
+	if !ok {
+		return lerror(L, "nyagos.lock: the 1st argument is not a string")
+	}
+	f, ok := L.Get(2).(*lua.LFunction)
+	if !ok {
+		return lerror(L, "nyagos.lock: the 2nd argument is not a function")
+	}
+	namedMutex.Lock()
+	m, ok := namedLocks[string(name)]
+	if !ok {
+		m = new(sync.Mutex)
+		namedLocks[string(name)] = m
+	}
+	namedMutex.Unlock()
+
+	top := L.GetTop()
+	L.Push(f)
+	for i := 3; i <= top; i++ {
+		L.Push(L.Get(i))
+	}
+	m.Lock()
+	err := L.PCall(top-2, lua.MultRet, nil)
+	m.Unlock()
+	if err != nil {
+		return lerror(L, err.Error())
+	}
+	return L.GetTop() - top
+}
+
+// cmdChannel is `nyagos.channel(NAME[,SIZE])` which returns the channel
+// named NAME. All Lua instances get the same channel by the same NAME.
+func cmdChannel(L Lua) int {
+	name, ok := L.Get(1).(lua.LString)
+	if !ok {
+		return lerror(L, "nyagos.channel: the 1st argument is not a string")
+	}
+	size := 0
+	if n, ok := L.Get(2).(lua.LNumber); ok {
+		size = int(n)
+	}
+	namedMutex.Lock()
+	ch, ok := namedChannel[string(name)]
+	if !ok {
+		ch = lua.LChannel(make(chan lua.LValue, size))
+		namedChannel[string(name)] = ch
+	}
+	namedMutex.Unlock()
+	L.Push(ch)
+	return 1
+}
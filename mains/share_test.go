@@ -0,0 +1,92 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestShareCopyBack(t *testing.T) {
+	L, err := NewLua()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer L.Close()
+	saveMain := mainLua
+	mainLua = L
+	defer func() { mainLua = saveMain }()
+
+	if err := L.DoString(`share.x = 1 ; share.y = "y" ; share.t = {a=1}`); err != nil {
+		t.Fatal(err.Error())
+	}
+	wrapper := &luaWrapper{Lua: L}
+	_, clone, err := wrapper.Clone(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	L2 := clone.(*luaWrapper).Lua
+	if err := L2.DoString(`share.x = 2 ; share.y = nil ; share.t.b = 2`); err != nil {
+		t.Fatal(err.Error())
+	}
+	clone.Close()
+	mergeShare(L)
+
+	if err := L.DoString(`ok = (share.x == 2 and share.y == nil and share.t.a == 1 and share.t.b == 2)`); err != nil {
+		t.Fatal(err.Error())
+	}
+	if L.GetGlobal("ok") != lua.LTrue {
+		t.Fatal("share[] was not copied back")
+	}
+}
+
+func TestShared(t *testing.T) {
+	L1, err := NewLua()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer L1.Close()
+	L2, err := getLua()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer L2.Close()
+
+	code1 := `
+		nyagos.shared.list = {1,2}
+		nyagos.lock("x", function(n) nyagos.shared.count = n end, 3)
+		nyagos.channel("c",1):send("hello")`
+	if err := L1.DoString(code1); err != nil {
+		t.Fatal(err.Error())
+	}
+	code2 := `
+		local list = nyagos.shared.list
+		list[3] = 3
+		_, msg = nyagos.channel("c"):receive()
+		ok = (#list == 3 and #nyagos.shared.list == 2 and nyagos.shared.count == 3 and msg == "hello")`
+	if err := L2.DoString(code2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if L2.GetGlobal("ok") != lua.LTrue {
+		t.Fatal("nyagos.shared[] or nyagos.channel() did not work")
+	}
+
+	if err := L1.DoString(`nyagos.shared.f = function() end`); err == nil {
+		t.Error("nyagos.shared[] accepted the function")
+	}
+	if err := L1.DoString(`nyagos.shared.t = setmetatable({}, {__index={x=1}})`); err != nil {
+		t.Fatal(err.Error())
+	}
+	code3 := `
+		local t = nyagos.shared.t
+		getmetatable(t).__index.x = 2
+		ok = (t.x == 2 and nyagos.shared.t.x == 1)`
+	if err := L2.DoString(code3); err != nil {
+		t.Fatal(err.Error())
+	}
+	if L2.GetGlobal("ok") != lua.LTrue {
+		t.Fatal("the metatable of nyagos.shared[] was not copied")
+	}
+}
@@ -0,0 +1,296 @@
+// +build !vanilla
+
+package mains
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/shell"
+)
+
+// luaProcess is the process object returned by nyagos.spawn
+type luaProcess struct {
+	process  *os.Process
+	done     chan struct{}
+	waited   bool
+	exitCode int
+	err      error
+	stdin    lua.LValue
+	stdout   lua.LValue
+	stderr   lua.LValue
+}
+
+// reap waits for the process in the background not to leave it as
+// a zombie even when PROCESS:wait() is never called.
+func (this *luaProcess) reap() {
+	this.exitCode, this.err = shell.WaitProcess(nil, this.process)
+	close(this.done)
+}
+
+// wait waits for the process to finish. It kills the process when ctx
+// is done while waiting.
+func (this *luaProcess) wait(ctx context.Context) {
+	if ctx != nil {
+		select {
+		case <-this.done:
+		case <-ctx.Done():
+			os.Stderr.WriteString("^C\n")
+			this.process.Kill()
+			<-this.done
+		}
+	} else {
+		<-this.done
+	}
+	this.waited = true
+}
+
+var spawnSignals = map[string]os.Signal{
+	"kill": os.Kill,
+	"int":  os.Interrupt,
+	"term": syscall.SIGTERM,
+}
+
+// sameEnvName compares the names of the environment variables.
+// They are case-insensitive only on Windows.
+func sameEnvName(name1, name2 string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(name1, name2)
+	}
+	return name1 == name2
+}
+
+func checkProcess(L Lua) (*luaProcess, bool) {
+	ud, ok := L.Get(1).(*lua.LUserData)
+	if !ok {
+		return nil, false
+	}
+	p, ok := ud.Value.(*luaProcess)
+	return p, ok
+}
+
+// processWait is `PROCESS:wait()` which returns the exit code.
+func processWait(L Lua) int {
+	p, ok := checkProcess(L)
+	if !ok {
+		return lerror(L, "(process)wait: not a process object")
+	}
+	ctx, _ := getRegInt(L)
+	p.wait(ctx)
+	if p.err != nil {
+		return lerror(L, p.err.Error())
+	}
+	L.Push(lua.LNumber(p.exitCode))
+	return 1
+}
+
+// processKill is `PROCESS:kill([SIGNAL])`. SIGNAL is "KILL"(default),
+// "INT", "TERM" or the number.
+func processKill(L Lua) int {
+	p, ok := checkProcess(L)
+	if !ok {
+		return lerror(L, "(process)kill: not a process object")
+	}
+	sig := os.Kill
+	switch value := L.Get(2).(type) {
+	case lua.LString:
+		name := strings.TrimPrefix(strings.ToLower(string(value)), "sig")
+		if sig, ok = spawnSignals[name]; !ok {
+			return lerror(L, fmt.Sprintf("(process)kill: unknown signal `%s`", value))
+		}
+	case lua.LNumber:
+		sig = syscall.Signal(int(value))
+	}
+	if err := p.process.Signal(sig); err != nil {
+		return lerror(L, err.Error())
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
+
+func processIndex(L Lua) int {
+	p, ok := checkProcess(L)
+	if !ok {
+		return lerror(L, "(process): not a process object")
+	}
+	switch L.ToString(2) {
+	case "pid":
+		L.Push(lua.LNumber(p.process.Pid))
+	case "exitcode":
+		if p.waited && p.err == nil {
+			L.Push(lua.LNumber(p.exitCode))
+		} else {
+			L.Push(lua.LNil)
+		}
+	case "wait":
+		L.Push(L.NewFunction(processWait))
+	case "kill":
+		L.Push(L.NewFunction(processKill))
+	case "stdin":
+		L.Push(p.stdin)
+	case "stdout":
+		L.Push(p.stdout)
+	case "stderr":
+		L.Push(p.stderr)
+	default:
+		L.Push(lua.LNil)
+	}
+	return 1
+}
+
+// spawnFile returns the file given to the child process for the option
+// `stdin`, `stdout` and `stderr`. When the option is "pipe", parent is
+// the other end of the pipe for nyagos.
+func spawnFile(value lua.LValue, std *os.File, isInput bool) (child, parent *os.File, err error) {
+	switch value {
+	case lua.LNil, lua.LString("inherit"):
+		return std, nil, nil
+	case lua.LString("null"):
+		if isInput {
+			child, err = os.Open(os.DevNull)
+		} else {
+			child, err = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		}
+		return child, nil, err
+	case lua.LString("pipe"):
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if isInput {
+			return r, w, nil
+		}
+		return w, r, nil
+	}
+	return nil, nil, fmt.Errorf("`%s` is not supported", value.String())
+}
+
+// cmdSpawn is `nyagos.spawn{CMD,ARGS...,stdin=,stdout=,stderr=,env=,cwd=}`
+// which starts the process without waiting and returns the process object.
+func cmdSpawn(L Lua) int {
+	table, ok := L.Get(1).(*lua.LTable)
+	if !ok {
+		return lerror(L, "nyagos.spawn: the 1st argument is not a table")
+	}
+	n := table.Len()
+	if n <= 0 {
+		return lerror(L, "nyagos.spawn: no command")
+	}
+	args := make([]string, n)
+	for i := 0; i < n; i++ {
+		args[i] = L.GetTable(table, lua.LNumber(i+1)).String()
+	}
+
+	_, sh := getRegInt(L)
+	if sh == nil {
+		sh = shell.New()
+	}
+	cmd := sh.Command()
+	cmd.SetArgs(args)
+
+	var childFiles []*os.File // closed after starting the process
+	defer func() {
+		for _, f := range childFiles {
+			f.Close()
+		}
+	}()
+	var parentFiles []*os.File // closed when failed
+	failed := func(err error) int {
+		for _, f := range parentFiles {
+			f.Close()
+		}
+		return lerror(L, "nyagos.spawn: "+err.Error())
+	}
+
+	stdin, stdinPipe, err := spawnFile(L.GetField(table, "stdin"), cmd.Stdin, true)
+	if err != nil {
+		return failed(err)
+	}
+	if stdin != cmd.Stdin {
+		childFiles = append(childFiles, stdin)
+	}
+	if stdinPipe != nil {
+		parentFiles = append(parentFiles, stdinPipe)
+	}
+	stdout, stdoutPipe, err := spawnFile(L.GetField(table, "stdout"), cmd.Stdout, false)
+	if err != nil {
+		return failed(err)
+	}
+	if stdout != cmd.Stdout {
+		childFiles = append(childFiles, stdout)
+	}
+	if stdoutPipe != nil {
+		parentFiles = append(parentFiles, stdoutPipe)
+	}
+	var stderr, stderrPipe *os.File
+	if L.GetField(table, "stderr") == lua.LString("stdout") {
+		stderr = stdout
+	} else {
+		stderr, stderrPipe, err = spawnFile(L.GetField(table, "stderr"), cmd.Stderr, false)
+		if err != nil {
+			return failed(err)
+		}
+		if stderr != cmd.Stderr {
+			childFiles = append(childFiles, stderr)
+		}
+		if stderrPipe != nil {
+			parentFiles = append(parentFiles, stderrPipe)
+		}
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+
+	if env, ok := L.GetField(table, "env").(*lua.LTable); ok {
+		// the variables of env override the ones of nyagos.
+		cmd.Env = os.Environ()
+		env.ForEach(func(key, value lua.LValue) {
+			prefix := key.String() + "="
+			for i := len(cmd.Env) - 1; i >= 0; i-- {
+				if len(cmd.Env[i]) >= len(prefix) && sameEnvName(cmd.Env[i][:len(prefix)], prefix) {
+					cmd.Env = append(cmd.Env[:i], cmd.Env[i+1:]...)
+				}
+			}
+			if value != lua.LFalse {
+				cmd.Env = append(cmd.Env, prefix+value.String())
+			}
+		})
+	}
+	if cwd, ok := L.GetField(table, "cwd").(lua.LString); ok {
+		cmd.Dir = string(cwd)
+	}
+
+	process, err := cmd.Start()
+	if err != nil {
+		return failed(err)
+	}
+
+	p := &luaProcess{
+		process: process,
+		done:    make(chan struct{}),
+		stdin:   lua.LNil,
+		stdout:  lua.LNil,
+		stderr:  lua.LNil,
+	}
+	go p.reap()
+	if stdinPipe != nil {
+		p.stdin = newIoLuaWriter(L, stdinPipe, stdinPipe, nil)
+	}
+	if stdoutPipe != nil {
+		p.stdout = newIoLuaReader(L, stdoutPipe, stdoutPipe, nil)
+	}
+	if stderrPipe != nil {
+		p.stderr = newIoLuaReader(L, stderrPipe, stderrPipe, nil)
+	}
+	ud := L.NewUserData()
+	ud.Value = p
+	meta := L.NewTable()
+	L.SetField(meta, "__index", L.NewFunction(processIndex))
+	L.SetMetatable(ud, meta)
+	L.Push(ud)
+	return 1
+}
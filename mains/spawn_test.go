@@ -0,0 +1,59 @@
+// +build !vanilla,!windows
+
+package mains
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/zetamatta/nyagos/shell"
+)
+
+func TestSpawn(t *testing.T) {
+	L, err := NewLua()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer L.Close()
+	setContext(L, context.WithValue(context.Background(), shellKey, shell.New()))
+
+	code := `
+		local p = nyagos.spawn{"cat",stdin="pipe",stdout="pipe"}
+		p.stdin:write("hello\n")
+		p.stdin:close()
+		line = p.stdout:read("*l")
+		pid = p.pid
+		before = p.exitcode
+		code1 = p:wait()
+		after = p.exitcode
+
+		local q = nyagos.spawn{"sh","-c","echo $FOO ; echo $foo ; pwd ; exit 3",
+			stdout="pipe",env={FOO="bar",foo="baz"},cwd="/"}
+		foo = q.stdout:read("*l")
+		lowerfoo = q.stdout:read("*l")
+		cwd = q.stdout:read("*l")
+		code2 = q:wait()`
+	if err := L.DoString(code); err != nil {
+		t.Fatal(err.Error())
+	}
+	expect := map[string]lua.LValue{
+		"line":     lua.LString("hello"),
+		"before":   lua.LNil,
+		"code1":    lua.LNumber(0),
+		"after":    lua.LNumber(0),
+		"foo":      lua.LString("bar"),
+		"lowerfoo": lua.LString("baz"),
+		"cwd":      lua.LString("/"),
+		"code2":    lua.LNumber(3),
+	}
+	for name, value := range expect {
+		if result := L.GetGlobal(name); result != value {
+			t.Errorf("%s = %v (expected %v)", name, result, value)
+		}
+	}
+	if _, ok := L.GetGlobal("pid").(lua.LNumber); !ok {
+		t.Error("pid is not a number")
+	}
+}
@@ -104,6 +104,7 @@ func Main() error {
 				return 0, nil
 			})
 		stream1 = constream
+		defer constream.Close()
 		frame.DefaultHistory = constream.History
 		ctx = context.WithValue(ctx, history.PackageId, constream.History)
 	} else {
@@ -0,0 +1,151 @@
+package nodos
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExeIndexInterval is the interval to check whether the directories
+// in the executable index are updated.
+var ExeIndexInterval = time.Second
+
+type exeDir struct {
+	mtime     time.Time
+	checkedAt time.Time
+	// names maps the normalized filename to the real filename.
+	names map[string]string
+}
+
+var (
+	exeIndex      = map[string]*exeDir{}
+	exeIndexMutex sync.Mutex
+)
+
+func readExeDir(dir string) *exeDir {
+	d := &exeDir{names: map[string]string{}, checkedAt: time.Now()}
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return d
+	}
+	d.mtime = stat.ModTime()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return d
+	}
+	for _, file1 := range files {
+		if isIndexedFile(dir, file1) {
+			d.names[normName(file1.Name())] = file1.Name()
+		}
+	}
+	return d
+}
+
+// getExeDir returns the index of dir. It is read again when the timestamp
+// of dir has been changed since it was read.
+func getExeDir(dir string) *exeDir {
+	key := normName(filepath.Clean(dir))
+	exeIndexMutex.Lock()
+	defer exeIndexMutex.Unlock()
+	d, ok := exeIndex[key]
+	if ok {
+		if time.Since(d.checkedAt) < ExeIndexInterval {
+			return d
+		}
+		d.checkedAt = time.Now()
+		if stat, err := os.Stat(dir); err == nil && stat.ModTime().Equal(d.mtime) {
+			return d
+		}
+	}
+	d = readExeDir(dir)
+	exeIndex[key] = d
+	return d
+}
+
+// ClearExeIndex drops all the cached directories.
+func ClearExeIndex() {
+	exeIndexMutex.Lock()
+	exeIndex = map[string]*exeDir{}
+	exeIndexMutex.Unlock()
+}
+
+// find returns the filename in the directory for the command name.
+func (d *exeDir) find(name string) string {
+	if ext := filepath.Ext(name); ext != "" || !requireExt {
+		if f, ok := d.names[normName(name)]; ok {
+			return f
+		}
+	}
+	if !requireExt {
+		return ""
+	}
+	for _, ext1 := range filepath.SplitList(os.Getenv("PATHEXT")) {
+		if f, ok := d.names[normName(name+ext1)]; ok {
+			return f
+		}
+	}
+	return ""
+}
+
+func envDirs(envnames ...string) []string {
+	result := []string{}
+	for _, name1 := range envnames {
+		for _, dir1 := range filepath.SplitList(os.Getenv(name1)) {
+			if dir1 = strings.TrimSpace(dir1); dir1 != "" {
+				result = append(result, dir1)
+			}
+		}
+	}
+	return result
+}
+
+// FindExecutable looks for the command name in the directories listed by
+// the environment variables `envnames` with the executable index.
+func FindExecutable(name string, envnames ...string) string {
+	for _, dir1 := range envDirs(envnames...) {
+		if f := getExeDir(dir1).find(name); f != "" {
+			return resolveLink(dir1, filepath.Join(dir1, f))
+		}
+	}
+	return ""
+}
+
+// ListExecutables returns the names of all executables in the directories
+// listed by the environment variable `envname`.
+func ListExecutables(ctx context.Context, envname string) ([]string, error) {
+	result := []string{}
+	for _, dir1 := range envDirs(envname) {
+		if ctx != nil {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			default:
+			}
+		}
+		for _, name := range getExeDir(dir1).names {
+			if isExecutableName(name) {
+				result = append(result, name)
+			}
+		}
+	}
+	return result, nil
+}
+
+func resolveLink(dir1, path string) string {
+	stat, err := os.Lstat(path)
+	if err != nil || stat.Mode()&os.ModeSymlink == 0 {
+		return path
+	}
+	linkTo, err := os.Readlink(path)
+	if err != nil || linkTo == "" {
+		return path
+	}
+	if !filepath.IsAbs(linkTo) {
+		linkTo = filepath.Join(dir1, linkTo)
+	}
+	return linkTo
+}
@@ -0,0 +1,97 @@
+package nodos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setupExeIndexTest(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "nyagos-exeindex")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	savePath := os.Getenv("PATH")
+	savePathExt := os.Getenv("PATHEXT")
+	saveInterval := ExeIndexInterval
+	os.Setenv("PATH", dir)
+	os.Setenv("PATHEXT", ".EXE;.BAT")
+	ExeIndexInterval = 10 * time.Millisecond
+	ClearExeIndex()
+	return dir, func() {
+		os.Setenv("PATH", savePath)
+		os.Setenv("PATHEXT", savePathExt)
+		ExeIndexInterval = saveInterval
+		ClearExeIndex()
+		os.RemoveAll(dir)
+	}
+}
+
+func writeExecutable(t *testing.T, path string) {
+	if err := ioutil.WriteFile(path, []byte("@echo off\n"), 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+// waitExeIndex waits until the index is checked again and the timestamp
+// of the directory changes.
+func waitExeIndex() {
+	time.Sleep(ExeIndexInterval + 20*time.Millisecond)
+}
+
+func TestExeIndexAdded(t *testing.T) {
+	dir, cleanup := setupExeIndexTest(t)
+	defer cleanup()
+
+	if path := FindExecutable("newtool.exe", "PATH"); path != "" {
+		t.Fatalf("FindExecutable(newtool.exe) = %s before it is made", path)
+	}
+	waitExeIndex()
+	expect := filepath.Join(dir, "newtool.exe")
+	writeExecutable(t, expect)
+	waitExeIndex()
+	if path := FindExecutable("newtool.exe", "PATH"); path != expect {
+		t.Fatalf("FindExecutable(newtool.exe) = %s (expected %s)", path, expect)
+	}
+}
+
+func TestExeIndexRemoved(t *testing.T) {
+	dir, cleanup := setupExeIndexTest(t)
+	defer cleanup()
+
+	target := filepath.Join(dir, "oldtool.exe")
+	writeExecutable(t, target)
+	if path := FindExecutable("oldtool.exe", "PATH"); path != target {
+		t.Fatalf("FindExecutable(oldtool.exe) = %s (expected %s)", path, target)
+	}
+	waitExeIndex()
+	if err := os.Remove(target); err != nil {
+		t.Fatal(err.Error())
+	}
+	waitExeIndex()
+	if path := FindExecutable("oldtool.exe", "PATH"); path != "" {
+		t.Fatalf("FindExecutable(oldtool.exe) = %s after it is removed", path)
+	}
+}
+
+func TestExeIndexLookPath(t *testing.T) {
+	dir, cleanup := setupExeIndexTest(t)
+	defer cleanup()
+
+	writeExecutable(t, filepath.Join(dir, "tool.exe"))
+	writeExecutable(t, filepath.Join(dir, "Mixed.bat"))
+
+	names := []string{"tool.exe", "Mixed.bat", "notfound.exe"}
+	if requireExt {
+		names = append(names, "tool", "TOOL.EXE", "mixed", "notfound")
+	}
+	for _, name := range names {
+		// the result of LookPath before the index was introduced.
+		expect := lookPath(dir, filepath.Join(dir, name))
+		if path := LookPath(LookCurdirNever, name); path != expect {
+			t.Fatalf("LookPath(%s) = %s (expected %s)", name, path, expect)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// +build !windows
+
+package nodos
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// requireExt is false because executables on UNIX have no suffix.
+const requireExt = false
+
+func normName(name string) string {
+	return name
+}
+
+func isIndexedFile(dir string, f os.FileInfo) bool {
+	if f.Mode()&os.ModeSymlink != 0 {
+		stat, err := os.Stat(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return false
+		}
+		f = stat
+	}
+	return f.Mode().IsRegular() && f.Mode().Perm()&0111 != 0
+}
+
+func isExecutableName(name string) bool {
+	return true
+}
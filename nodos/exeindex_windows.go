@@ -0,0 +1,28 @@
+package nodos
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// requireExt is true because a file without suffix can not be executed.
+const requireExt = true
+
+func normName(name string) string {
+	return strings.ToUpper(name)
+}
+
+func isIndexedFile(dir string, f os.FileInfo) bool {
+	return !f.IsDir() && filepath.Ext(f.Name()) != ""
+}
+
+func isExecutableName(name string) bool {
+	ext := filepath.Ext(name)
+	for _, ext1 := range filepath.SplitList(os.Getenv("PATHEXT")) {
+		if strings.EqualFold(ext, ext1) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package nodos
+
+import (
+	"os"
+)
+
+// LockFile locks the whole file exclusively. It waits until the other
+// processes unlock it.
+func LockFile(fd *os.File) error {
+	return lockFile(fd)
+}
+
+// UnlockFile releases the lock by LockFile.
+func UnlockFile(fd *os.File) error {
+	return unlockFile(fd)
+}
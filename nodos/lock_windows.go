@@ -0,0 +1,42 @@
+package nodos
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procLockFileEx = kernel32.NewProc("LockFileEx")
+var procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+
+const _LOCKFILE_EXCLUSIVE_LOCK = 2
+
+func lockFile(fd *os.File) error {
+	var overlapped windows.Overlapped
+	rc, _, err := procLockFileEx.Call(
+		fd.Fd(),
+		_LOCKFILE_EXCLUSIVE_LOCK,
+		0,
+		0xFFFFFFFF,
+		0xFFFFFFFF,
+		uintptr(unsafe.Pointer(&overlapped)))
+	if rc == 0 {
+		return err
+	}
+	return nil
+}
+
+func unlockFile(fd *os.File) error {
+	var overlapped windows.Overlapped
+	rc, _, err := procUnlockFileEx.Call(
+		fd.Fd(),
+		0,
+		0xFFFFFFFF,
+		0xFFFFFFFF,
+		uintptr(unsafe.Pointer(&overlapped)))
+	if rc == 0 {
+		return err
+	}
+	return nil
+}
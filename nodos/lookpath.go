@@ -90,6 +90,13 @@ func LookPath(where LookCurdirT, name string, envnames ...string) string {
 		if _dir1 == "" {
 			continue
 		}
+		if _dir1 != "." {
+			// The directories on %PATH% are looked up with the cached index.
+			if f := getExeDir(_dir1).find(name); f != "" {
+				return resolveLink(_dir1, filepath.Join(_dir1, f))
+			}
+			continue
+		}
 		if path := lookPath(dir1, filepath.Join(_dir1, name)); path != "" {
 			// println("Found:" + path)
 			return path
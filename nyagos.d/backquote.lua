@@ -4,7 +4,6 @@ if not nyagos then
 end
 
 backquote = {
-    org = nyagos.filter,
     replace = function(m)
         m = string.sub(m,2,string.len(m)-1)
         local r = nyagos.eval(m)
@@ -19,14 +18,8 @@ backquote = {
     end
 }
 
-nyagos.filter = function(cmdline)
-    if backquote.org then
-        local cmdline_ = backquote.org(cmdline)
-        if cmdline_ then
-            cmdline = cmdline_
-        end
-    end
+nyagos.on("filter",function(cmdline)
     cmdline = cmdline:gsub('`[^`]*`',backquote.replace)
     cmdline = cmdline:gsub('%$(%b())',backquote.replace)
     return cmdline
-end
+end)
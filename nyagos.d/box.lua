@@ -59,7 +59,7 @@ share.__dump_history = function()
 end
 
 nyagos.key.C_x = function(this)
-    nyagos.write("\nC-x: [r]:command-history, [h]:cd-history, [g]:git-revision\n")
+    nyagos.write("\nC-x: [r]:command-history, [h]:cd-history, [g]:git-revision, [e]:editor\n")
     local ch = nyagos.getkey()
     local c = string.lower(string.char(ch))
     local result
@@ -73,6 +73,9 @@ nyagos.key.C_x = function(this)
     elseif c == 'g' or ch == bit32.band(string.byte('g'),0x1F) then
         result = nyagos.eval('git log --pretty="format:%h %s" | box')
         result = string.match(result,"^%S+") or ""
+    elseif c == 'e' or ch == bit32.band(string.byte('e'),0x1F) then
+        this:call("REPAINT_ON_NEWLINE")
+        return this:call("EDIT_AND_EXECUTE_COMMAND")
     end
     this:call("REPAINT_ON_NEWLINE")
     return result
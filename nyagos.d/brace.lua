@@ -3,14 +3,7 @@ if not nyagos then
     os.exit()
 end
 
-share.org_brace_filter = nyagos.filter
-nyagos.filter = function(cmdline)
-    if share.org_brace_filter then
-        local cmdline_ = share.org_brace_filter(cmdline)
-        if cmdline_ then
-            cmdline = cmdline_
-        end
-    end
+nyagos.on("filter",function(cmdline)
     local save={}
     local masking = function(s)
         local i=#save+1
@@ -50,4 +43,4 @@ nyagos.filter = function(cmdline)
         return save[s+0]
     end)
     return cmdline
-end
+end)
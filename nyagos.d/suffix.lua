@@ -32,14 +32,7 @@ suffix = setmetatable({},{
     __index = function(t,k) return share._suffixes[k] end
 })
 
-share._org_suffix_argsfilter=nyagos.argsfilter
-nyagos.argsfilter = function(args)
-    if share._org_suffix_argsfilter then
-        local args_ = share._org_suffix_argsfilter(args)
-        if args_ then
-            args = args_
-        end
-    end
+nyagos.on("argsfilter",function(args)
     local m = string.match(args[0],"%.(%w+)$")
     if not m then
         return
@@ -65,7 +58,7 @@ nyagos.argsfilter = function(args)
         newargs[#newargs+1] = args[i]
     end
     return newargs
-end
+end)
 
 nyagos.alias.suffix = function(args)
     if #args < 1 then
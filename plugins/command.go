@@ -0,0 +1,92 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Param is the interface of the parameters for CmdPlugin
+type Param interface {
+	Args() []string
+	Out() io.Writer
+	Err() io.Writer
+}
+
+// SubCommands are the names of the sub-commands of `plugin`.
+var SubCommands = []string{"list", "info", "enable", "disable", "install", "uninstall"}
+
+func printList(out io.Writer) {
+	for _, p := range List() {
+		mark := " "
+		if p.Enabled {
+			mark = "*"
+		}
+		kind := "user"
+		if p.Catalog {
+			kind = "catalog"
+		}
+		fmt.Fprintf(out, "%s %-16s %-10s %-8s %s\n", mark, p.Name, p.Version, kind, p.Status())
+	}
+}
+
+func printInfo(out io.Writer, p *Plugin) {
+	fmt.Fprintf(out, "Name:        %s\n", p.Name)
+	fmt.Fprintf(out, "Version:     %s\n", p.Version)
+	fmt.Fprintf(out, "Description: %s\n", p.Description)
+	fmt.Fprintf(out, "Depends:     %s\n", strings.Join(p.Depends, ", "))
+	fmt.Fprintf(out, "Order:       %d\n", p.Order)
+	fmt.Fprintf(out, "Path:        %s\n", p.Path)
+	fmt.Fprintf(out, "Enabled:     %v\n", p.Enabled)
+	fmt.Fprintf(out, "Status:      %s\n", p.Status())
+}
+
+// CmdPlugin is the built-in command `plugin`
+func CmdPlugin(ctx context.Context, cmd Param) (int, error) {
+	args := cmd.Args()
+	if len(args) < 2 || args[1] == "list" {
+		printList(cmd.Out())
+		return 0, nil
+	}
+	if len(args) < 3 {
+		return 1, fmt.Errorf("plugin %s: requires parameters", args[1])
+	}
+	switch args[1] {
+	case "info":
+		for _, name := range args[2:] {
+			p, ok := Find(name)
+			if !ok {
+				return 1, fmt.Errorf("plugin info: %s: plugin not found", name)
+			}
+			printInfo(cmd.Out(), p)
+		}
+	case "enable", "disable":
+		for _, name := range args[2:] {
+			if err := SetEnabled(name, args[1] == "enable"); err != nil {
+				return 1, fmt.Errorf("plugin %s: %s", args[1], err.Error())
+			}
+			fmt.Fprintf(cmd.Out(), "%s: %sd (from the next startup)\n", name, args[1])
+		}
+	case "install":
+		for _, src := range args[2:] {
+			p, err := Install(src)
+			if err != nil {
+				return 1, fmt.Errorf("plugin install: %s", err.Error())
+			}
+			fmt.Fprintf(cmd.Out(), "%s %s: installed to %s (loaded from the next startup)\n",
+				p.Name, p.Version, p.Path)
+		}
+	case "uninstall":
+		for _, name := range args[2:] {
+			if err := Uninstall(name); err != nil {
+				return 1, fmt.Errorf("plugin uninstall: %s", err.Error())
+			}
+			fmt.Fprintf(cmd.Out(), "%s: uninstalled\n", name)
+		}
+	default:
+		return 1, fmt.Errorf("plugin: %s: unknown sub-command (%s)",
+			args[1], strings.Join(SubCommands, ", "))
+	}
+	return 0, nil
+}
@@ -0,0 +1,191 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// extractTar extracts the tar archive (gzipped or not) of src into dst.
+// The symbolic links and the files out of dst are ignored.
+func extractTar(src, dst string) error {
+	fd, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	var r io.Reader = bufio.NewReader(fd)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1F && magic[1] == 0x8B {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || filepath.VolumeName(name) != "" ||
+			name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			continue
+		}
+		target := filepath.Join(dst, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0777)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+				return err
+			}
+			var w *os.File
+			w, err = os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode).Perm()|0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, tr)
+			if err1 := w.Close(); err == nil {
+				err = err1
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// findRoot returns the directory which has plugin.json in dir:
+// dir itself or its only subdirectory.
+func findRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ManifestName)); err == nil {
+		return dir, nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 1 && files[0].IsDir() {
+		sub := filepath.Join(dir, files[0].Name())
+		if _, err := os.Stat(filepath.Join(sub, ManifestName)); err == nil {
+			return sub, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found", ManifestName)
+}
+
+// Install copies the plugin from the directory or the tarball src into Dir.
+// The plugin of the same name installed already is replaced.
+func Install(src string) (*Plugin, error) {
+	if Dir == "" {
+		return nil, fmt.Errorf("the plugin directory is not set")
+	}
+	stat, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(Dir, 0777); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir(Dir, ".install")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if stat.IsDir() {
+		err = copyDir(src, tmp)
+	} else {
+		err = extractTar(src, tmp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	root, err := findRoot(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", src, err.Error())
+	}
+	p := readPluginDir(root)
+	if p.Err != nil {
+		return nil, fmt.Errorf("%s: %s", src, p.Err.Error())
+	}
+	dst := filepath.Join(Dir, p.Name)
+	if err := os.RemoveAll(dst); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(root, dst); err != nil {
+		return nil, err
+	}
+	p.Path = dst
+	return p, nil
+}
+
+// Uninstall removes the plugin installed in Dir.
+func Uninstall(name string) error {
+	p, ok := Find(name)
+	if !ok {
+		return fmt.Errorf("%s: plugin not found", name)
+	}
+	if p.Catalog {
+		return fmt.Errorf("%s: the catalog script can not be uninstalled", p.Name)
+	}
+	if err := os.RemoveAll(p.Path); err != nil {
+		return err
+	}
+	state := readState()
+	if _, ok := state[p.Name]; ok {
+		delete(state, p.Name)
+		return writeState(state)
+	}
+	return nil
+}
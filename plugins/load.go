@@ -0,0 +1,147 @@
+package plugins
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SlowThreshold is the time to load a plugin over which it is reported
+// at the startup.
+var SlowThreshold = 200 * time.Millisecond
+
+// sortPlugins returns the enabled plugins in the order to load.
+// The dependencies come first and the others are sorted by Order and
+// their names. The plugins whose dependencies are not found get Err.
+func sortPlugins(list []*Plugin) []*Plugin {
+	enabled := []*Plugin{}
+	byName := map[string]*Plugin{}
+	for _, p := range list {
+		byName[strings.ToLower(p.Name)] = p
+		if p.Enabled {
+			enabled = append(enabled, p)
+		}
+	}
+	sort.SliceStable(enabled, func(i, j int) bool {
+		if enabled[i].Order != enabled[j].Order {
+			return enabled[i].Order < enabled[j].Order
+		}
+		return strings.ToLower(enabled[i].Name) < strings.ToLower(enabled[j].Name)
+	})
+
+	result := make([]*Plugin, 0, len(enabled))
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	mark := map[*Plugin]int{}
+	var visit func(p *Plugin)
+	visit = func(p *Plugin) {
+		switch mark[p] {
+		case visiting:
+			p.Err = fmt.Errorf("circular dependency")
+			return
+		case visited:
+			return
+		}
+		mark[p] = visiting
+		for _, name := range p.Depends {
+			dep, ok := byName[strings.ToLower(name)]
+			if !ok {
+				p.Err = fmt.Errorf("depends on `%s` which is not installed", name)
+				continue
+			}
+			if !dep.Enabled {
+				p.Err = fmt.Errorf("depends on `%s` which is disabled", name)
+				continue
+			}
+			visit(dep)
+		}
+		mark[p] = visited
+		result = append(result, p)
+	}
+	for _, p := range enabled {
+		visit(p)
+	}
+	return result
+}
+
+// loadPlugin loads the scripts of p. The panic on loading is returned
+// as an error not to stop the other plugins.
+func loadPlugin(p *Plugin, shellEngine func(string) error,
+	langEngine func(string) ([]byte, error)) (err error) {
+
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("panic: %v", e)
+		}
+	}()
+	files, err := p.files()
+	if err != nil {
+		return err
+	}
+	for _, fname := range files {
+		switch strings.ToLower(filepath.Ext(fname)) {
+		case ".ny":
+			err = shellEngine(fname)
+		default:
+			_, err = langEngine(fname)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", filepath.Base(fname), err.Error())
+		}
+	}
+	return nil
+}
+
+// Load loads the enabled plugins and reports the errors, the slow plugins
+// and the summary of the time to load them to warn.
+func Load(shellEngine func(string) error,
+	langEngine func(string) ([]byte, error), warn io.Writer) {
+
+	loaded = map[string]*Plugin{}
+	count := 0
+	var total time.Duration
+	var slowest *Plugin
+	list := List()
+	for _, p := range list {
+		loaded[strings.ToLower(p.Name)] = p
+	}
+	for _, p := range sortPlugins(list) {
+		if p.Err == nil {
+			for _, name := range p.Depends {
+				if dep := loaded[strings.ToLower(name)]; dep != nil && !dep.Loaded {
+					p.Err = fmt.Errorf("depends on `%s` which failed", name)
+				}
+			}
+		}
+		if p.Err != nil {
+			fmt.Fprintf(warn, "plugin %s: %s\n", p.Name, p.Err.Error())
+			continue
+		}
+		start := time.Now()
+		p.Err = loadPlugin(p, shellEngine, langEngine)
+		p.Elapsed = time.Since(start)
+		if p.Err != nil {
+			fmt.Fprintf(warn, "plugin %s: %s\n", p.Name, p.Err.Error())
+			continue
+		}
+		p.Loaded = true
+		if p.Elapsed >= SlowThreshold {
+			fmt.Fprintf(warn, "plugin %s: %s\n", p.Name, p.Status())
+		}
+		count++
+		total += p.Elapsed
+		if slowest == nil || p.Elapsed > slowest.Elapsed {
+			slowest = p
+		}
+	}
+	if count > 0 {
+		fmt.Fprintf(warn, "plugins: %d loaded in %s (slowest: %s %s)\n",
+			count, total.Round(time.Millisecond/10),
+			slowest.Name, slowest.Elapsed.Round(time.Millisecond/10))
+	}
+}
@@ -0,0 +1,247 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the file name of the manifest in the plugin directory.
+const ManifestName = "plugin.json"
+
+// Dir is the per-user directory where the plugins are installed.
+var Dir = ""
+
+// CatalogDir is the directory of the optional scripts bundled with
+// nyagos (nyagos.d/catalog). They are listed as the plugins disabled
+// by default.
+var CatalogDir = ""
+
+// Manifest is the contents of plugin.json
+type Manifest struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Depends     []string `json:"depends,omitempty"`
+	// Order decides the order of the plugins which do not depend on
+	// each other. The smaller is loaded first.
+	Order int `json:"order,omitempty"`
+	// Files are the scripts to load. When it is empty, all *.lua and
+	// *.ny in the directory are loaded in the order of their names.
+	Files []string `json:"files,omitempty"`
+}
+
+// Plugin is an installed plugin or a catalog script.
+type Plugin struct {
+	Manifest
+	Path    string // the directory or the file of the catalog script
+	Catalog bool
+	Enabled bool
+	Loaded  bool
+	Err     error
+	Elapsed time.Duration
+}
+
+// ParseManifest reads a Manifest from JSON text.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if err := checkName(m.Name); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func checkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%s: name is not set", ManifestName)
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\:*?"<>|`) {
+		return fmt.Errorf("%s: invalid name `%s`", ManifestName, name)
+	}
+	return nil
+}
+
+func readPluginDir(dir string) *Plugin {
+	p := &Plugin{Path: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		p.Name = filepath.Base(dir)
+		p.Err = err
+		return p
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		p.Name = filepath.Base(dir)
+		p.Err = err
+		return p
+	}
+	p.Manifest = *m
+	return p
+}
+
+// files returns the paths of the scripts to load.
+func (p *Plugin) files() ([]string, error) {
+	if p.Catalog {
+		return []string{p.Path}, nil
+	}
+	if len(p.Files) > 0 {
+		result := make([]string, len(p.Files))
+		for i, f := range p.Files {
+			result[i] = filepath.Join(p.Path, filepath.FromSlash(f))
+		}
+		return result, nil
+	}
+	files, err := ioutil.ReadDir(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(f.Name())) {
+		case ".lua", ".ny":
+			result = append(result, filepath.Join(p.Path, f.Name()))
+		}
+	}
+	return result, nil
+}
+
+// Status returns the result of loading at the startup.
+func (p *Plugin) Status() string {
+	if p.Err != nil {
+		return "error: " + p.Err.Error()
+	}
+	if p.Loaded {
+		return fmt.Sprintf("loaded in %s", p.Elapsed.Round(time.Millisecond/10))
+	}
+	if p.Enabled {
+		return "not loaded"
+	}
+	return "disabled"
+}
+
+func stateFile() string {
+	return filepath.Join(Dir, "enabled.json")
+}
+
+// readState returns the plugins enabled or disabled by the user.
+func readState() map[string]bool {
+	state := map[string]bool{}
+	if Dir == "" {
+		return state
+	}
+	data, err := ioutil.ReadFile(stateFile())
+	if err != nil {
+		return state
+	}
+	json.Unmarshal(data, &state)
+	return state
+}
+
+func writeState(state map[string]bool) error {
+	if Dir == "" {
+		return fmt.Errorf("the plugin directory is not set")
+	}
+	if err := os.MkdirAll(Dir, 0777); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(stateFile(), data, 0666)
+}
+
+// loaded has the plugins by the last Load.
+var loaded = map[string]*Plugin{}
+
+// List returns the plugins installed in Dir and the scripts in CatalogDir.
+// The installed plugins are enabled by default and the catalog scripts
+// are not. The installed plugin hides the catalog script of the same name.
+func List() []*Plugin {
+	state := readState()
+	result := []*Plugin{}
+	names := map[string]bool{}
+	if Dir != "" {
+		if files, err := ioutil.ReadDir(Dir); err == nil {
+			for _, f := range files {
+				if !f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+					continue
+				}
+				p := readPluginDir(filepath.Join(Dir, f.Name()))
+				if names[strings.ToLower(p.Name)] {
+					continue
+				}
+				names[strings.ToLower(p.Name)] = true
+				enabled, ok := state[p.Name]
+				p.Enabled = enabled || !ok
+				result = append(result, p)
+			}
+		}
+	}
+	if CatalogDir != "" {
+		if files, err := ioutil.ReadDir(CatalogDir); err == nil {
+			for _, f := range files {
+				if f.IsDir() || !strings.EqualFold(filepath.Ext(f.Name()), ".lua") {
+					continue
+				}
+				name := f.Name()[:len(f.Name())-4]
+				if names[strings.ToLower(name)] {
+					continue
+				}
+				names[strings.ToLower(name)] = true
+				result = append(result, &Plugin{
+					Manifest: Manifest{Name: name},
+					Path:     filepath.Join(CatalogDir, f.Name()),
+					Catalog:  true,
+					Enabled:  state[name],
+				})
+			}
+		}
+	}
+	for i, p := range result {
+		if last, ok := loaded[strings.ToLower(p.Name)]; ok && last.Path == p.Path {
+			last.Enabled = p.Enabled
+			result[i] = last
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+// Find returns the plugin named name.
+func Find(name string) (*Plugin, bool) {
+	for _, p := range List() {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// SetEnabled enables or disables the plugin from the next startup.
+func SetEnabled(name string, enabled bool) error {
+	p, ok := Find(name)
+	if !ok {
+		return fmt.Errorf("%s: plugin not found", name)
+	}
+	state := readState()
+	if enabled == !p.Catalog {
+		delete(state, p.Name) // the default
+	} else {
+		state[p.Name] = enabled
+	}
+	return writeState(state)
+}
@@ -0,0 +1,157 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePlugin(t *testing.T, dir, manifest string, files map[string]string) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func setDirs(t *testing.T) (string, func()) {
+	tmp, err := ioutil.TempDir("", "nyagos-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveDir, saveCatalog := Dir, CatalogDir
+	Dir = filepath.Join(tmp, "plugins")
+	CatalogDir = filepath.Join(tmp, "catalog")
+	os.MkdirAll(Dir, 0777)
+	os.MkdirAll(CatalogDir, 0777)
+	return tmp, func() {
+		Dir, CatalogDir = saveDir, saveCatalog
+		loaded = map[string]*Plugin{}
+		os.RemoveAll(tmp)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	_, cleanup := setDirs(t)
+	defer cleanup()
+
+	writePlugin(t, filepath.Join(Dir, "a"), `{"name":"a","depends":["b"]}`,
+		map[string]string{"2.lua": "", "1.ny": "", "readme.txt": ""})
+	writePlugin(t, filepath.Join(Dir, "b"), `{"name":"b","order":10}`,
+		map[string]string{"b.lua": ""})
+	writePlugin(t, filepath.Join(Dir, "c"), `{"name":"c"}`,
+		map[string]string{"c.lua": "error"})
+	writePlugin(t, filepath.Join(Dir, "d"), `{"name":"d","depends":["c"]}`,
+		map[string]string{"d.lua": ""})
+	writePlugin(t, filepath.Join(Dir, "e"), `{"name":"e","depends":["x"]}`, nil)
+	writePlugin(t, filepath.Join(Dir, "f"), `{"name":"f","files":["z.lua"]}`,
+		map[string]string{"y.lua": "", "z.lua": ""})
+	ioutil.WriteFile(filepath.Join(CatalogDir, "cat.lua"), []byte(""), 0666)
+
+	loadedFiles := []string{}
+	engine := func(fname string) error {
+		if data, _ := ioutil.ReadFile(fname); string(data) == "error" {
+			return errors.New("failed")
+		}
+		loadedFiles = append(loadedFiles, filepath.Base(fname))
+		return nil
+	}
+	var warn bytes.Buffer
+	Load(engine, func(fname string) ([]byte, error) { return nil, engine(fname) }, &warn)
+
+	expect := "b.lua 1.ny 2.lua z.lua"
+	if result := strings.Join(loadedFiles, " "); result != expect {
+		t.Errorf("loaded %s (expected %s)", result, expect)
+	}
+	for _, name := range []string{"c", "d", "e"} {
+		if !strings.Contains(warn.String(), "plugin "+name+":") {
+			t.Errorf("the error of %s is not reported: %s", name, warn.String())
+		}
+	}
+	if !strings.Contains(warn.String(), "plugins: 3 loaded in ") {
+		t.Errorf("the summary is not reported: %s", warn.String())
+	}
+	if p, ok := Find("cat"); !ok || !p.Catalog || p.Enabled || p.Loaded {
+		t.Errorf("catalog: %+v", p)
+	}
+	if p, _ := Find("a"); !p.Loaded {
+		t.Errorf("a: %s", p.Status())
+	}
+
+	if err := SetEnabled("cat", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetEnabled("a", false); err != nil {
+		t.Fatal(err)
+	}
+	loadedFiles = loadedFiles[:0]
+	warn.Reset()
+	Load(engine, func(fname string) ([]byte, error) { return nil, engine(fname) }, &warn)
+	expect = "cat.lua z.lua b.lua"
+	if result := strings.Join(loadedFiles, " "); result != expect {
+		t.Errorf("loaded %s (expected %s)", result, expect)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	tmp, cleanup := setDirs(t)
+	defer cleanup()
+
+	src := filepath.Join(tmp, "src")
+	writePlugin(t, src, `{"name":"foo","version":"1.0"}`, map[string]string{"foo.lua": ""})
+	p, err := Install(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "foo" || p.Version != "1.0" {
+		t.Fatalf("%+v", p)
+	}
+	if _, err := os.Stat(filepath.Join(Dir, "foo", "foo.lua")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct{ name, body string }{
+		{"foo-2.0/plugin.json", `{"name":"foo","version":"2.0"}`},
+		{"foo-2.0/foo.lua", ""},
+		{"../evil.lua", ""},
+	} {
+		tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(f.body))
+	}
+	tw.Close()
+	gz.Close()
+	tarball := filepath.Join(tmp, "foo.tar.gz")
+	ioutil.WriteFile(tarball, buffer.Bytes(), 0666)
+
+	p, err = Install(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != "2.0" {
+		t.Fatalf("%+v", p)
+	}
+	if _, err := os.Stat(filepath.Join(Dir, "evil.lua")); err == nil {
+		t.Fatal("the file out of the directory was extracted")
+	}
+	if err := Uninstall("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Find("foo"); ok {
+		t.Fatal("foo was not uninstalled")
+	}
+}
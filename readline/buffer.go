@@ -51,6 +51,11 @@ type Buffer struct {
 	TermWidth      int // == TopColumn + ViewWidth + forbiddenWidth
 	TopColumn      int // == width of Prompt
 	HistoryPointer int
+	suggestion     []rune
+
+	rightPrompt      string
+	rightPromptWidth int
+	rightPromptShown bool
 }
 
 func (this *Buffer) ViewWidth() int {
@@ -191,6 +196,8 @@ func (this *Buffer) RepaintAfterPrompt() {
 func (this *Buffer) RepaintAll() {
 	this.Out.Flush()
 	this.TopColumn, _ = this.Prompt()
+	this.loadRightPrompt()
+	this.rightPromptShown = false
 	this.RepaintAfterPrompt()
 }
 
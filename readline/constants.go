@@ -104,18 +104,25 @@ const (
 
 const (
 	F_ACCEPT_LINE          = "ACCEPT_LINE"
+	F_ACCEPT_SUGGESTION    = "ACCEPT_SUGGESTION"
 	F_BACKWARD_CHAR        = "BACKWARD_CHAR"
 	F_BACKWARD_DELETE_CHAR = "BACKWARD_DELETE_CHAR"
 	F_BEGINNING_OF_LINE    = "BEGINNING_OF_LINE"
 	F_CLEAR_SCREEN         = "CLEAR_SCREEN"
 	F_DELETE_CHAR          = "DELETE_CHAR"
 	F_DELETE_OR_ABORT      = "DELETE_OR_ABORT"
+	F_EDIT_AND_EXECUTE     = "EDIT_AND_EXECUTE_COMMAND"
+	F_EDIT_COMMAND_LINE    = "EDIT_COMMAND_LINE"
 	F_END_OF_LINE          = "END_OF_LINE"
 	F_FORWARD_CHAR         = "FORWARD_CHAR"
+	F_FORWARD_WORD         = "FORWARD_WORD"
 	F_HISTORY_DOWN         = "HISTORY_DOWN" // for compatible
 	F_HISTORY_UP           = "HISTORY_UP"   // for compatible
 	F_NEXT_HISTORY         = "NEXT_HISTORY"
 	F_PREVIOUS_HISTORY     = "PREVIOUS_HISTORY"
+	F_FUZZY_DIRECTORY      = "FUZZY_DIRECTORY"
+	F_FUZZY_FILE           = "FUZZY_FILE"
+	F_FUZZY_HISTORY        = "FUZZY_HISTORY"
 	F_INTR                 = "INTR"
 	F_ISEARCH_BACKWARD     = "ISEARCH_BACKWARD"
 	F_KILL_LINE            = "KILL_LINE"
@@ -232,14 +239,18 @@ var name2char = map[string]string{
 
 var NAME2FUNC = map[string]func(context.Context, *Buffer) Result{
 	F_ACCEPT_LINE:          keyFuncEnter,
+	F_ACCEPT_SUGGESTION:    keyFuncAcceptSuggestion,
 	F_BACKWARD_CHAR:        keyFuncBackword,
 	F_BACKWARD_DELETE_CHAR: keyFuncBackSpace,
 	F_BEGINNING_OF_LINE:    keyFuncHead,
 	F_CLEAR_SCREEN:         keyFuncCLS,
 	F_DELETE_CHAR:          keyFuncDelete,
 	F_DELETE_OR_ABORT:      keyFuncDeleteOrAbort,
+	F_EDIT_AND_EXECUTE:     keyFuncEditAndExecute,
+	F_EDIT_COMMAND_LINE:    keyFuncEditCommandLine,
 	F_END_OF_LINE:          keyFuncTail,
 	F_FORWARD_CHAR:         keyFuncForward,
+	F_FORWARD_WORD:         keyFuncForwardWord,
 	F_HISTORY_DOWN:         keyFuncHistoryDown, // for compatible
 	F_HISTORY_UP:           keyFuncHistoryUp,   // for compatible
 	F_NEXT_HISTORY:         keyFuncHistoryDown,
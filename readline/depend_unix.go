@@ -0,0 +1,5 @@
+// +build !windows
+
+package readline
+
+const defaultEditor = "vi"
@@ -0,0 +1,3 @@
+package readline
+
+const defaultEditor = "notepad"
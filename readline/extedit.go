@@ -0,0 +1,147 @@
+package readline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mattn/go-tty"
+)
+
+// getEditor returns the command-line to call the editor
+// by %VISUAL% or %EDITOR%.
+func getEditor() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+			return strings.Fields(value)
+		}
+	}
+	return []string{defaultEditor}
+}
+
+// joinLines converts the multi-line text from the editor into
+// one command-line whose statements are separated with ` ; `.
+func joinLines(text string) string {
+	var buffer strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" {
+			continue
+		}
+		if buffer.Len() > 0 {
+			buffer.WriteString(" ; ")
+		}
+		buffer.WriteString(line)
+	}
+	return buffer.String()
+}
+
+func (this *Buffer) watchWindowSize() {
+	ws := this.TTY.SIGWINCH()
+	go func(lastw int) {
+		for ws1 := range ws {
+			w := ws1.W
+			if lastw != w {
+				mu.Lock()
+				this.TermWidth = w
+				fmt.Fprintf(this.Out, "\x1B[%dG", this.TopColumn+1)
+				this.RepaintAfterPrompt()
+				this.RepaintWithHighlight()
+				this.loadRightPrompt()
+				this.rightPromptShown = false
+				this.RepaintRightPrompt()
+				this.Out.Flush()
+				mu.Unlock()
+				lastw = w
+			}
+		}
+	}(this.TermWidth)
+}
+
+func (this *Buffer) callEditor(fname string) error {
+	this.Out.WriteByte('\n')
+	io.WriteString(this.Out, ansiCursorOn)
+	this.Out.Flush()
+
+	// release the terminal for the editor
+	this.TTY.Close()
+	defer func() {
+		if tty1, err := tty.Open(); err == nil {
+			this.TTY = tty1
+			if w, _, err := tty1.Size(); err == nil {
+				this.TermWidth = w
+			}
+			this.watchWindowSize()
+		} else {
+			this.TTY = nil
+		}
+		io.WriteString(this.Out, ansiCursorOff)
+	}()
+
+	args := getEditor()
+	args = append(args, fname)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// editCommandLine edits the current buffer with the external editor
+// and reloads the result. It returns false when the buffer was not updated.
+func (this *Buffer) editCommandLine() (bool, error) {
+	fd, err := ioutil.TempFile("", "nyagos*.txt")
+	if err != nil {
+		return false, err
+	}
+	fname := fd.Name()
+	defer os.Remove(fname)
+
+	fmt.Fprintln(fd, this.String())
+	if err := fd.Close(); err != nil {
+		return false, err
+	}
+	if err := this.callEditor(fname); err != nil {
+		return false, err
+	}
+	text, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return false, err
+	}
+	this.Length = 0
+	this.Cursor = 0
+	this.ViewStart = 0
+	this.Cursor = this.InsertString(0, joinLines(string(text)))
+	return true, nil
+}
+
+func keyFuncEditCommandLine(ctx context.Context, this *Buffer) Result {
+	_, err := this.editCommandLine()
+	if this.TTY == nil {
+		return INTR
+	}
+	if err != nil {
+		fmt.Fprintf(this.Out, "%s\n", err.Error())
+	}
+	this.RepaintAll()
+	return CONTINUE
+}
+
+func keyFuncEditAndExecute(ctx context.Context, this *Buffer) Result {
+	ok, err := this.editCommandLine()
+	if this.TTY == nil {
+		return INTR
+	}
+	if err != nil {
+		fmt.Fprintf(this.Out, "%s\n", err.Error())
+	}
+	this.RepaintAll()
+	if !ok || this.Length <= 0 {
+		return CONTINUE
+	}
+	return ENTER
+}
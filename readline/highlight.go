@@ -0,0 +1,89 @@
+package readline
+
+import (
+	"strings"
+)
+
+// EnableHighlight is the switch to call Editor.Highlighter on repainting.
+var EnableHighlight = false
+
+const ansiReset = "\x1B[0m"
+
+// parseHighlight converts the text which escape sequences are inserted into
+// by the highlighter to the list of sequences for each character.
+// When the characters of `colored` differ from `source`, it returns nil.
+func parseHighlight(colored string, source []rune) []string {
+	result := make([]string, 0, len(source))
+	var current strings.Builder
+	reader := strings.NewReader(colored)
+	for reader.Len() > 0 {
+		ch, _, _ := reader.ReadRune()
+		if ch == '\x1B' {
+			var seq strings.Builder
+			seq.WriteRune(ch)
+			for reader.Len() > 0 {
+				ch, _, _ = reader.ReadRune()
+				seq.WriteRune(ch)
+				if ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') {
+					break
+				}
+			}
+			if s := seq.String(); s == ansiReset || s == "\x1B[m" {
+				current.Reset()
+			} else {
+				current.WriteString(s)
+			}
+			continue
+		}
+		if len(result) >= len(source) || source[len(result)] != ch {
+			return nil
+		}
+		result = append(result, current.String())
+	}
+	if len(result) != len(source) {
+		return nil
+	}
+	return result
+}
+
+func (this *Buffer) highlightColors() []string {
+	if !EnableHighlight || this.Highlighter == nil || this.Length <= 0 {
+		return nil
+	}
+	source := this.Buffer[:this.Length]
+	return parseHighlight(this.Highlighter(string(source)), source)
+}
+
+// RepaintWithHighlight redraws the visible part of the buffer
+// with the colors given by Editor.Highlighter.
+func (this *Buffer) RepaintWithHighlight() {
+	colors := this.highlightColors()
+	if colors == nil {
+		return
+	}
+	this.Backspace(this.GetWidthBetween(this.ViewStart, this.Cursor))
+	last := ""
+	w := 0
+	bs := 0
+	for i := this.ViewStart; i < this.Length; i++ {
+		w1 := GetCharWidth(this.Buffer[i])
+		if w+w1 >= this.ViewWidth() {
+			break
+		}
+		if colors[i] != last {
+			this.Out.WriteString(ansiReset)
+			this.Out.WriteString(colors[i])
+			last = colors[i]
+		}
+		this.PutRune(this.Buffer[i])
+		w += w1
+		if i >= this.Cursor {
+			bs += w1
+		}
+	}
+	if last != "" {
+		this.Out.WriteString(ansiReset)
+	}
+	this.Eraseline()
+	this.Backspace(bs)
+}
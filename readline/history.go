@@ -12,12 +12,25 @@ type IHistory interface {
 }
 
 type Editor struct {
-	History IHistory
-	Writer  io.Writer
-	Out     *bufio.Writer
-	Prompt  func() (int, error)
-	Default string
-	Cursor  int
+	History     IHistory
+	Writer      io.Writer
+	Out         *bufio.Writer
+	Prompt      func() (int, error)
+	Highlighter func(string) string
+	Suggester   func(string) string
+	Default     string
+	Cursor      int
+	// PromptLines returns the number of the lines of the prompt above
+	// the line where the text is typed.
+	PromptLines func() int
+	// RightPrompt returns the text shown at the right end of the line
+	// and its width.
+	RightPrompt func() (string, int)
+	// TransientPrompt returns the prompt which replaces the prompt
+	// of the line accepted and its width.
+	TransientPrompt func() (string, int)
+	// current is the buffer being edited by ReadLine (guarded by mu)
+	current *Buffer
 }
 
 func keyFuncHistoryUp(ctx context.Context, this *Buffer) Result {
@@ -43,6 +43,9 @@ func keyFuncBackword(ctx context.Context, this *Buffer) Result { // Ctrl-B
 }
 
 func keyFuncTail(ctx context.Context, this *Buffer) Result { // Ctrl-E
+	if this.acceptSuggestion(-1) {
+		return CONTINUE
+	}
 	allength := this.GetWidthBetween(this.ViewStart, this.Length)
 	if allength < this.ViewWidth() {
 		for ; this.Cursor < this.Length; this.Cursor++ {
@@ -73,6 +76,7 @@ func keyFuncTail(ctx context.Context, this *Buffer) Result { // Ctrl-E
 
 func keyFuncForward(ctx context.Context, this *Buffer) Result { // Ctrl-F
 	if this.Cursor >= this.Length {
+		this.acceptSuggestion(-1)
 		return CONTINUE
 	}
 	w := this.GetWidthBetween(this.ViewStart, this.Cursor+1)
@@ -91,6 +91,7 @@ var keyMap = map[string]KeyFuncT{
 	name2char[K_SHIFT]:     name2func(F_PASS),
 	name2char[K_DOWN]:      name2func(F_HISTORY_DOWN),
 	name2char[K_UP]:        name2func(F_HISTORY_UP),
+	name2char[K_ALT_F]:     name2func(F_FORWARD_WORD),
 	name2char[K_ALT_V]:     name2func(F_YANK),
 	name2char[K_ALT_Y]:     name2func(F_YANK_WITH_QUOTE),
 }
@@ -171,6 +172,11 @@ func getKey(tty1 *tty.TTY) (string, error) {
 	}
 }
 
+// GetKey reads one key sequence from the terminal.
+func (this *Buffer) GetKey() (string, error) {
+	return getKey(this.TTY)
+}
+
 var mu sync.Mutex
 
 // Call LineEditor
@@ -209,7 +215,11 @@ func (session *Editor) ReadLine(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("go-tty.Open: %s", err.Error())
 	}
 	this.TTY = tty1
-	defer tty1.Close()
+	defer func() {
+		if this.TTY != nil {
+			this.TTY.Close()
+		}
+	}()
 
 	this.TermWidth, _, err = tty1.Size()
 	if err != nil {
@@ -227,28 +237,28 @@ func (session *Editor) ReadLine(ctx context.Context) (string, error) {
 		io.WriteString(this.Out, "\n")
 		this.TopColumn = 0
 	}
+	this.loadRightPrompt()
 	this.InsertString(0, session.Default)
 	if this.Cursor > this.Length {
 		this.Cursor = this.Length
 	}
 	this.RepaintAfterPrompt()
+	this.RepaintWithHighlight()
+	this.RepaintSuggestion()
+	this.RepaintRightPrompt()
 
 	cursorOnSwitch := false
 
-	ws := tty1.SIGWINCH()
-	go func(lastw int) {
-		for ws1 := range ws {
-			w := ws1.W
-			if lastw != w {
-				mu.Lock()
-				this.TermWidth = w
-				fmt.Fprintf(this.Out, "\x1B[%dG", this.TopColumn+1)
-				this.RepaintAfterPrompt()
-				mu.Unlock()
-				lastw = w
-			}
-		}
-	}(this.TermWidth)
+	this.watchWindowSize()
+
+	mu.Lock()
+	session.current = &this
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		session.current = nil
+		mu.Unlock()
+	}()
 
 	for {
 		mu.Lock()
@@ -259,7 +269,7 @@ func (session *Editor) ReadLine(ctx context.Context) (string, error) {
 		this.Out.Flush()
 
 		mu.Unlock()
-		key1, err := getKey(tty1)
+		key1, err := getKey(this.TTY)
 		if err != nil {
 			return "", err
 		}
@@ -279,13 +289,25 @@ func (session *Editor) ReadLine(ctx context.Context) (string, error) {
 			cursorOnSwitch = false
 		}
 		rc := f.Call(ctx, &this)
+		if rc == CONTINUE && this.TTY != nil {
+			this.RepaintWithHighlight()
+			this.RepaintSuggestion()
+			this.RepaintRightPrompt()
+		}
 		if rc != CONTINUE {
+			if len(this.suggestion) > 0 {
+				this.Eraseline()
+			}
+			if rc == ENTER {
+				this.repaintTransientPrompt()
+			}
 			this.Out.WriteByte('\n')
 			if !cursorOnSwitch {
 				io.WriteString(this.Out, ansiCursorOn)
 			}
 			this.Out.Flush()
 			result := this.String()
+			session.current = nil
 			mu.Unlock()
 			if rc == ENTER {
 				return result, nil
@@ -298,3 +320,60 @@ func (session *Editor) ReadLine(ctx context.Context) (string, error) {
 		mu.Unlock()
 	}
 }
+
+// RefreshPrompt prints the prompt and the text being typed again.
+// It can be called from other goroutines while ReadLine waits for keys.
+func (session *Editor) RefreshPrompt() {
+	mu.Lock()
+	defer mu.Unlock()
+	this := session.current
+	if this == nil || this.TTY == nil {
+		return
+	}
+	io.WriteString(this.Out, ansiCursorOff)
+	this.Out.WriteByte('\r')
+	if session.PromptLines != nil {
+		if n := session.PromptLines(); n > 0 {
+			fmt.Fprintf(this.Out, "\x1B[%dA", n)
+		}
+	}
+	io.WriteString(this.Out, "\x1B[0J")
+	this.RepaintAll()
+	this.RepaintWithHighlight()
+	this.RepaintSuggestion()
+	this.RepaintRightPrompt()
+	io.WriteString(this.Out, ansiCursorOn)
+	this.Out.Flush()
+}
+
+// Interrupt calls f while ReadLine waits for keys and returns true.
+// The prompt is erased before calling f so that f can print messages,
+// and is printed again after f. When ReadLine is not waiting for keys,
+// f is not called and Interrupt returns false.
+func (session *Editor) Interrupt(f func()) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	this := session.current
+	if this == nil || this.TTY == nil {
+		return false
+	}
+	io.WriteString(this.Out, ansiCursorOff)
+	this.Out.WriteByte('\r')
+	if session.PromptLines != nil {
+		if n := session.PromptLines(); n > 0 {
+			fmt.Fprintf(this.Out, "\x1B[%dA", n)
+		}
+	}
+	io.WriteString(this.Out, "\x1B[0J")
+	this.Out.Flush()
+
+	f()
+
+	this.RepaintAll()
+	this.RepaintWithHighlight()
+	this.RepaintSuggestion()
+	this.RepaintRightPrompt()
+	io.WriteString(this.Out, ansiCursorOn)
+	this.Out.Flush()
+	return true
+}
@@ -0,0 +1,69 @@
+package readline
+
+import (
+	"fmt"
+)
+
+// EnableTransientPrompt is the switch to replace the prompt of the line
+// accepted with Editor.TransientPrompt's result.
+var EnableTransientPrompt = false
+
+// loadRightPrompt gets the text of the right prompt from Editor.RightPrompt
+func (this *Buffer) loadRightPrompt() {
+	if this.RightPrompt == nil {
+		this.rightPrompt, this.rightPromptWidth = "", 0
+		return
+	}
+	this.rightPrompt, this.rightPromptWidth = this.RightPrompt()
+}
+
+// RepaintRightPrompt draws the right prompt at the right end of the line
+// when the text typed and the ghost text do not reach it.
+// Otherwise it erases the right prompt.
+func (this *Buffer) RepaintRightPrompt() {
+	if this.rightPromptWidth <= 0 {
+		return
+	}
+	used := this.TopColumn + this.GetWidthBetween(this.ViewStart, this.Length)
+	if this.Cursor >= this.Length {
+		for _, ch := range this.suggestion {
+			used += GetCharWidth(ch)
+		}
+	}
+	if max := this.TopColumn + this.ViewWidth(); used > max {
+		used = max
+	}
+	cursor := this.TopColumn + this.GetWidthBetween(this.ViewStart, this.Cursor)
+	// The last column is not used not to scroll the screen.
+	start := this.TermWidth - this.rightPromptWidth - 1
+	if used < start {
+		fmt.Fprintf(this.Out, "\x1B[%dG%s%s\x1B[%dG",
+			start+1, this.rightPrompt, ansiReset, cursor+1)
+		this.rightPromptShown = true
+	} else if this.rightPromptShown {
+		fmt.Fprintf(this.Out, "\x1B[%dG\x1B[0K\x1B[%dG", used+1, cursor+1)
+		this.rightPromptShown = false
+	}
+}
+
+// repaintTransientPrompt replaces the prompt and the right prompt of the
+// line accepted with Editor.TransientPrompt's result and prints the whole
+// text typed.
+func (this *Buffer) repaintTransientPrompt() {
+	if !EnableTransientPrompt || this.TransientPrompt == nil {
+		return
+	}
+	this.Out.WriteByte('\r')
+	if this.PromptLines != nil {
+		if n := this.PromptLines(); n > 0 {
+			fmt.Fprintf(this.Out, "\x1B[%dA", n)
+		}
+	}
+	this.Out.WriteString("\x1B[0J")
+	prompt, _ := this.TransientPrompt()
+	this.Out.WriteString(prompt)
+	for i := 0; i < this.Length; i++ {
+		this.PutRune(this.Buffer[i])
+	}
+	this.rightPromptShown = false
+}
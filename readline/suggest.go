@@ -0,0 +1,97 @@
+package readline
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// EnableSuggestion is the switch to show Editor.Suggester's result
+// after the cursor as ghost text.
+var EnableSuggestion = false
+
+// SuggestionColor is the escape sequence to draw the ghost text.
+var SuggestionColor = "\x1B[0;90m"
+
+// nextSuggestion returns the rest of the text that Editor.Suggester proposes
+// for the current buffer.
+func (this *Buffer) nextSuggestion() []rune {
+	if !EnableSuggestion || this.Suggester == nil || this.Length <= 0 || this.Cursor < this.Length {
+		return nil
+	}
+	current := string(this.Buffer[:this.Length])
+	suggestion := this.Suggester(current)
+	if len(suggestion) <= len(current) || !strings.HasPrefix(suggestion, current) {
+		return nil
+	}
+	return []rune(suggestion[len(current):])
+}
+
+// RepaintSuggestion draws the ghost text after the cursor
+// and erases the old one.
+func (this *Buffer) RepaintSuggestion() {
+	if len(this.suggestion) > 0 && this.Cursor < this.Length {
+		this.Repaint(this.Cursor, 1)
+		this.RepaintWithHighlight()
+	}
+	this.suggestion = this.nextSuggestion()
+	if this.Cursor < this.Length {
+		return
+	}
+	rest := this.ViewWidth() - this.GetWidthBetween(this.ViewStart, this.Length)
+	w := 0
+	if len(this.suggestion) > 0 {
+		this.Out.WriteString(SuggestionColor)
+		for _, ch := range this.suggestion {
+			w1 := GetCharWidth(ch)
+			if w+w1 >= rest {
+				break
+			}
+			this.PutRune(ch)
+			w += w1
+		}
+		this.Out.WriteString(ansiReset)
+	}
+	this.Eraseline()
+	this.Backspace(w)
+}
+
+// acceptSuggestion inserts the first n characters of the ghost text.
+// When n < 0, it inserts all of them.
+func (this *Buffer) acceptSuggestion(n int) bool {
+	if len(this.suggestion) <= 0 || this.Cursor < this.Length {
+		return false
+	}
+	if n < 0 || n > len(this.suggestion) {
+		n = len(this.suggestion)
+	}
+	this.InsertAndRepaint(string(this.suggestion[:n]))
+	this.suggestion = nil
+	return true
+}
+
+func keyFuncAcceptSuggestion(ctx context.Context, this *Buffer) Result {
+	this.acceptSuggestion(-1)
+	return CONTINUE
+}
+
+func keyFuncForwardWord(ctx context.Context, this *Buffer) Result { // M-F
+	if this.Cursor >= this.Length {
+		n := 0
+		for n < len(this.suggestion) && unicode.IsSpace(this.suggestion[n]) {
+			n++
+		}
+		for n < len(this.suggestion) && !unicode.IsSpace(this.suggestion[n]) {
+			n++
+		}
+		this.acceptSuggestion(n)
+		return CONTINUE
+	}
+	for this.Cursor < this.Length && unicode.IsSpace(this.Buffer[this.Cursor]) {
+		keyFuncForward(ctx, this)
+	}
+	for this.Cursor < this.Length && !unicode.IsSpace(this.Buffer[this.Cursor]) {
+		keyFuncForward(ctx, this)
+	}
+	return CONTINUE
+}
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/zetamatta/go-findfile"
@@ -65,6 +66,8 @@ type Cmd struct {
 	fullPath        string
 	UseShellExecute bool
 	Closers         []io.Closer
+	Env             []string // nil means os.Environ()
+	Dir             string   // "" means the current directory
 }
 
 func (cmd *Cmd) Arg(n int) string      { return cmd.args[n] }
@@ -153,6 +156,18 @@ var OnCommandNotFound = func(ctx context.Context, cmd *Cmd, err error) error {
 
 var LastErrorLevel int
 
+// PipeStatus is the exit codes of the commands of the last pipeline
+// executed in the foreground.
+var PipeStatus []int
+
+var backgroundJobs int32
+
+// BackgroundJobs returns the number of the pipelines running
+// in the background with `&`.
+func BackgroundJobs() int {
+	return int(atomic.LoadInt32(&backgroundJobs))
+}
+
 func makeCmdline(args, rawargs []string) string {
 	var buffer strings.Builder
 	for i, s := range args {
@@ -213,6 +228,13 @@ func (cmd *Cmd) spawnvpSilent(ctx context.Context) (int, error) {
 	return cmd.startProcess(ctx)
 }
 
+func (cmd *Cmd) environ() []string {
+	if cmd.Env != nil {
+		return cmd.Env
+	}
+	return os.Environ()
+}
+
 func startAndWaitProcess(ctx context.Context, name string, args []string, procAttr *os.ProcAttr) (int, error) {
 	if ctx != nil {
 		select {
@@ -226,7 +248,26 @@ func startAndWaitProcess(ctx context.Context, name string, args []string, procAt
 	if err != nil {
 		return 255, err
 	}
+	return WaitProcess(ctx, process)
+}
 
+// Start starts the executable without waiting for it to finish.
+// Aliases, functions and batchfiles are not supported.
+func (cmd *Cmd) Start() (*os.Process, error) {
+	if len(cmd.args) <= 0 {
+		return nil, errors.New("no command")
+	}
+	fullpath := cmd.FullPath()
+	if fullpath == "" {
+		return nil, &CommandNotFound{cmd.args[0], os.ErrNotExist}
+	}
+	cmd.args[0] = fullpath
+	return os.StartProcess(fullpath, cmd.args, cmd.procAttr())
+}
+
+// WaitProcess waits for the process to finish and returns its exit code.
+// The process is killed when ctx is canceled.
+func WaitProcess(ctx context.Context, process *os.Process) (int, error) {
 	if ctx != nil {
 		done := make(chan struct{})
 		go func() {
@@ -341,6 +382,12 @@ func (sh *Shell) Interpret(ctx context.Context, text string) (errorlevel int, fi
 			}
 		}
 		var wg sync.WaitGroup
+		pipeStatus := make([]int, len(pipeline))
+		// the number of the commands of the pipeline running with `&`
+		var job *int32
+		if isBackGround && !sh.IsBackGround {
+			job = new(int32)
+		}
 		shutdown_immediately := false
 		for i, state := range pipeline {
 			if defined.DBG {
@@ -387,6 +434,7 @@ func (sh *Shell) Interpret(ctx context.Context, text string) (errorlevel int, fi
 				// foreground execution.
 				errorlevel, finalerr = cmd.Spawnvp(ctx)
 				LastErrorLevel = errorlevel
+				pipeStatus[i] = errorlevel
 				cmd.Close()
 			} else {
 				// background
@@ -409,22 +457,33 @@ func (sh *Shell) Interpret(ctx context.Context, text string) (errorlevel int, fi
 						cmd.SetTag(newtag)
 					}
 				}
-				go func(ctx1 context.Context, cmd1 *Cmd) {
+				if job != nil && atomic.AddInt32(job, 1) == 1 {
+					atomic.AddInt32(&backgroundJobs, 1)
+				}
+				go func(ctx1 context.Context, cmd1 *Cmd, status *int) {
 					if !isBackGround {
 						defer wg.Done()
 					}
-					cmd1.Spawnvp(ctx1)
+					if job != nil {
+						defer func() {
+							if atomic.AddInt32(job, -1) == 0 {
+								atomic.AddInt32(&backgroundJobs, -1)
+							}
+						}()
+					}
+					*status, _ = cmd1.Spawnvp(ctx1)
 					if tag := cmd1.Tag(); tag != nil {
 						if err := tag.Close(); err != nil {
 							fmt.Fprintln(os.Stderr, err.Error())
 						}
 					}
 					cmd1.Close()
-				}(newctx, cmd)
+				}(newctx, cmd, &pipeStatus[i])
 			}
 		}
 		if !isBackGround {
 			wg.Wait()
+			PipeStatus = pipeStatus
 			if shutdown_immediately {
 				return errorlevel, nil
 			}
@@ -3,12 +3,14 @@ package shell
 import (
 	"context"
 	"fmt"
+	"os"
 	"testing"
 )
 
 func TestInterpret(t *testing.T) {
 	ctx := context.Background()
 	_, err := New().Interpret(ctx, "ls | cat -n > hogehoge")
+	defer os.Remove("hogehoge")
 	if err != nil {
 		fmt.Println(err.Error())
 	} else {
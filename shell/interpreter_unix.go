@@ -6,22 +6,37 @@ import (
 	"context"
 	"os"
 	"os/exec"
+	"strings"
+
+	"github.com/zetamatta/nyagos/nodos"
 )
 
-func (cmd *Cmd) lookpath() string {
-	path, err := exec.LookPath(cmd.args[0])
+// LookPath returns the fullpath of the executable `name`.
+func LookPath(name string) string {
+	if !strings.Contains(name, "/") {
+		return nodos.FindExecutable(name, "PATH")
+	}
+	path, err := exec.LookPath(name)
 	if err != nil {
 		return ""
 	}
 	return path
 }
 
-func (cmd *Cmd) startProcess(ctx context.Context) (int, error) {
-	procAttr := &os.ProcAttr{
-		Env:   os.Environ(),
+func (cmd *Cmd) lookpath() string {
+	return LookPath(cmd.args[0])
+}
+
+func (cmd *Cmd) procAttr() *os.ProcAttr {
+	return &os.ProcAttr{
+		Dir:   cmd.Dir,
+		Env:   cmd.environ(),
 		Files: []*os.File{cmd.Stdin, cmd.Stdout, cmd.Stderr},
 	}
-	return startAndWaitProcess(ctx, cmd.args[0], cmd.args, procAttr)
+}
+
+func (cmd *Cmd) startProcess(ctx context.Context) (int, error) {
+	return startAndWaitProcess(ctx, cmd.args[0], cmd.args, cmd.procAttr())
 }
 
 func isGui(path string) bool {
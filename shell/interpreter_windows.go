@@ -13,8 +13,13 @@ import (
 	"github.com/zetamatta/nyagos/nodos"
 )
 
+// LookPath returns the fullpath of the executable `name`.
+func LookPath(name string) string {
+	return nodos.LookPath(LookCurdirOrder, name, "NYAGOSPATH")
+}
+
 func (cmd *Cmd) lookpath() string {
-	return nodos.LookPath(LookCurdirOrder, cmd.args[0], "NYAGOSPATH")
+	return LookPath(cmd.args[0])
 }
 
 func (cmd *Cmd) startProcess(ctx context.Context) (int, error) {
@@ -43,14 +48,18 @@ func (cmd *Cmd) startProcess(ctx context.Context) (int, error) {
 		}
 	}
 
+	return startAndWaitProcess(ctx, cmd.args[0], cmd.args, cmd.procAttr())
+}
+
+func (cmd *Cmd) procAttr() *os.ProcAttr {
 	cmdline := makeCmdline(cmd.args, cmd.rawArgs)
 
-	procAttr := &os.ProcAttr{
-		Env:   os.Environ(),
+	return &os.ProcAttr{
+		Dir:   cmd.Dir,
+		Env:   cmd.environ(),
 		Files: []*os.File{cmd.Stdin, cmd.Stdout, cmd.Stderr},
 		Sys:   &syscall.SysProcAttr{CmdLine: cmdline},
 	}
-	return startAndWaitProcess(ctx, cmd.args[0], cmd.args, procAttr)
 }
 
 func isGui(path string) bool {
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 // Stream is the inteface which can read command-line
@@ -14,6 +15,15 @@ type Stream interface {
 	ReadLine(context.Context) (context.Context, string, error)
 }
 
+// ExecHooker is the interface of the Stream whose PreExec is called
+// before Loop executes the command-line read from it, and whose PostExec
+// is called after that with its exit code and the time it took.
+// The streams for source, if, foreach and so on do not implement it.
+type ExecHooker interface {
+	PreExec(ctx context.Context, sh *Shell, line string)
+	PostExec(ctx context.Context, sh *Shell, line string, rc int, d time.Duration)
+}
+
 func (ses *session) push(lines []string) {
 	if lines != nil && len(lines) >= 1 {
 		ses.unreadline = append(ses.unreadline, lines...)
@@ -89,10 +99,18 @@ func (sh *Shell) Loop(ctx0 context.Context, stream Stream) (int, error) {
 				}
 			}
 		}(sigint, quit, cancel)
+		hooker, _ := stream.(ExecHooker)
+		if hooker != nil {
+			hooker.PreExec(ctx, sh, line)
+		}
+		start := time.Now()
 		rc, err := sh.Interpret(ctx, line)
 		signal.Stop(sigint)
 		close(quit)
 		close(sigint)
+		if hooker != nil {
+			hooker.PostExec(ctx0, sh, line, rc, time.Since(start))
+		}
 
 		if err != nil {
 			if err == io.EOF {
@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"unicode"
+)
+
+// TokenKind is the type of the token of the command-line.
+type TokenKind int
+
+const (
+	// TokenWord is a command-name or an argument.
+	TokenWord TokenKind = iota
+	// TokenTerm is the terminator of the statement: `;`, `|`, `||`, `&`, `&&` or `|&`
+	TokenTerm
+	// TokenRedirect is the redirect operator: `<`, `>`, `>>`, `2>`, `>&1`, `>!`, ...
+	TokenRedirect
+)
+
+// Token is a word of the command-line with its position.
+type Token struct {
+	Kind TokenKind
+	Text string // raw text which may have quotation marks
+	Pos  int    // byte offset where the token starts
+	End  int    // byte offset where the token ends
+	// Quote is the quotation mark which is not closed at the end of the token.
+	// It is NOTQUOTED when all quotations are closed.
+	Quote rune
+}
+
+// Tokenize splits the command-line into words, terminators and redirect
+// operators with the same rules as Parse. It does not expand variables
+// and does not fail on the incomplete command-line being typed.
+func Tokenize(text string) []Token {
+	tokens := []Token{}
+	quoteNow := NOTQUOTED
+	yenCount := 0
+	lastchar := ' '
+	wordStart := -1
+
+	termWord := func(end int) {
+		if wordStart >= 0 {
+			tokens = append(tokens, Token{
+				Kind:  TokenWord,
+				Text:  text[wordStart:end],
+				Pos:   wordStart,
+				End:   end,
+				Quote: quoteNow,
+			})
+			wordStart = -1
+		}
+	}
+	// appendOp appends the operator or joins it with the previous one
+	// when they are continuous (`||`, `>>`, `>&1`...)
+	appendOp := func(kind TokenKind, pos, end int) {
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind && tokens[n-1].End == pos {
+			tokens[n-1].End = end
+			tokens[n-1].Text = text[tokens[n-1].Pos:end]
+			return
+		}
+		tokens = append(tokens, Token{Kind: kind, Text: text[pos:end], Pos: pos, End: end})
+	}
+
+	for i, ch := range text {
+		end := i + len(string(ch))
+		if quoteNow == NOTQUOTED {
+			if yenCount%2 == 0 && (ch == '"' || ch == '\'') {
+				quoteNow = ch
+			}
+		} else if yenCount%2 == 0 && ch == quoteNow {
+			quoteNow = NOTQUOTED
+			yenCount = 0
+			lastchar = ch
+			continue
+		}
+		if quoteNow != NOTQUOTED {
+			if wordStart < 0 {
+				wordStart = i
+			}
+		} else if unicode.IsSpace(ch) {
+			termWord(i)
+		} else if unicode.IsSpace(lastchar) && ch == '#' {
+			break
+		} else if unicode.IsSpace(lastchar) && ch == ';' {
+			appendOp(TokenTerm, i, end)
+		} else if (ch == '|' || ch == '!') && lastchar == '>' {
+			termWord(i)
+			appendOp(TokenRedirect, i, end)
+		} else if ch == '|' || ch == '&' && lastchar != '>' {
+			termWord(i)
+			appendOp(TokenTerm, i, end)
+		} else if ch == '&' {
+			// >&[n]
+			appendOp(TokenRedirect, i, end)
+		} else if (ch == '1' || ch == '2') && lastchar == '&' &&
+			len(tokens) > 0 && tokens[len(tokens)-1].Kind == TokenRedirect &&
+			tokens[len(tokens)-1].End == i {
+			appendOp(TokenRedirect, i, end)
+		} else if ch == '>' || ch == '<' {
+			if ch == '>' && (lastchar == '1' || lastchar == '2') && wordStart == i-1 {
+				// 1> or 2>
+				wordStart = -1
+				appendOp(TokenRedirect, i-1, end)
+			} else {
+				termWord(i)
+				appendOp(TokenRedirect, i, end)
+			}
+		} else if wordStart < 0 {
+			wordStart = i
+		}
+		if ch == '\\' {
+			yenCount++
+		} else {
+			yenCount = 0
+		}
+		lastchar = ch
+	}
+	termWord(len(text))
+	return tokens
+}
@@ -0,0 +1,42 @@
+package shell
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text   string
+		expect []string
+	}{
+		{`ls -l`, []string{`ls`, `-l`}},
+		{`type "a b" >out.txt`, []string{`type`, `"a b"`, `>`, `out.txt`}},
+		{`cmd 2>>err|more`, []string{`cmd`, `2>>`, `err`, `|`, `more`}},
+		{`make >&1 && echo ok`, []string{`make`, `>&1`, `&&`, `echo`, `ok`}},
+		{`echo a ; echo b # comment`, []string{`echo`, `a`, `;`, `echo`, `b`}},
+		{`echo "a;b|c`, []string{`echo`, `"a;b|c`}},
+		{`echo "a\"b c" d`, []string{`echo`, `"a\"b c"`, `d`}},
+		{`echo 'it\'s x' y`, []string{`echo`, `'it\'s x'`, `y`}},
+		{`echo "a\\" b`, []string{`echo`, `"a\\"`, `b`}},
+		{`echo "a\\\"b;c"|more`, []string{`echo`, `"a\\\"b;c"`, `|`, `more`}},
+	}
+	for _, test := range tests {
+		tokens := Tokenize(test.text)
+		if len(tokens) != len(test.expect) {
+			t.Fatalf("Tokenize(%q): %d tokens, expected %d", test.text, len(tokens), len(test.expect))
+		}
+		for i, token := range tokens {
+			if token.Text != test.expect[i] || test.text[token.Pos:token.End] != token.Text {
+				t.Fatalf("Tokenize(%q)[%d]=%q, expected %q", test.text, i, token.Text, test.expect[i])
+			}
+		}
+	}
+	tokens := Tokenize(`echo "abc`)
+	if q := tokens[len(tokens)-1].Quote; q != '"' {
+		t.Fatalf("Quote=%q, expected '\"'", q)
+	}
+	tokens = Tokenize(`echo "abc"`)
+	if q := tokens[len(tokens)-1].Quote; q != NOTQUOTED {
+		t.Fatalf("Quote=%q, expected NOTQUOTED", q)
+	}
+}